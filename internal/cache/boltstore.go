@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const storeFileName = "cache.db"
+
+// storeLockTimeout bounds how long an operation waits for another process
+// to release the store.
+const storeLockTimeout = 30 * time.Second
+
+var storeBucket = []byte("entries")
+
+// boltStorage keeps every name in a single bbolt database, so large
+// histories do not create thousands of files. bbolt locks the database
+// file for as long as it is open, so it is opened for each operation: a
+// long-running process such as serve must not block the other processes
+// sharing the cache. Reads share the lock; writes take it exclusively.
+type boltStorage struct {
+	dir  string
+	path string
+}
+
+func newBoltStorage(dir string) (*boltStorage, error) {
+	s := &boltStorage{
+		dir:  dir,
+		path: filepath.Join(dir, storeFileName),
+	}
+
+	// Create the database and its bucket, so that read-only opens find
+	// them.
+	err := s.update(func(b *bolt.Bucket) error {
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *boltStorage) open(readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{
+		Timeout:  storeLockTimeout,
+		ReadOnly: readOnly,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache store %s: %w", s.path, err)
+	}
+	return db, nil
+}
+
+// view runs fn in a read-only transaction.
+func (s *boltStorage) view(fn func(b *bolt.Bucket) error) error {
+	db, err := s.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket(storeBucket))
+	})
+}
+
+// update runs fn in a read-write transaction, creating the bucket if
+// needed.
+func (s *boltStorage) update(fn func(b *bolt.Bucket) error) error {
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(storeBucket)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+	if closeErr := db.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close cache store %s: %w", s.path, closeErr)
+	}
+
+	return err
+}
+
+func (s *boltStorage) Read(name string) ([]byte, error) {
+	var data []byte
+	err := s.view(func(b *bolt.Bucket) error {
+		value := b.Get([]byte(name))
+		if value == nil {
+			return fmt.Errorf("failed to read %s from %s: %w", name, s.path, os.ErrNotExist)
+		}
+		// Values are only valid during the transaction.
+		data = append([]byte(nil), value...)
+		return nil
+	})
+
+	return data, err
+}
+
+func (s *boltStorage) Write(name string, parts ...[]byte) error {
+	var size int
+	for _, part := range parts {
+		size += len(part)
+	}
+	data := make([]byte, 0, size)
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+
+	return s.update(func(b *bolt.Bucket) error {
+		return b.Put([]byte(name), data)
+	})
+}
+
+func (s *boltStorage) Remove(name string) error {
+	return s.update(func(b *bolt.Bucket) error {
+		return b.Delete([]byte(name))
+	})
+}
+
+func (s *boltStorage) List() ([]string, error) {
+	var names []string
+	err := s.view(func(b *bolt.Bucket) error {
+		// Keys are iterated in byte order, so names come out sorted.
+		return b.ForEach(func(k, v []byte) error {
+			names = append(names, string(k))
+			return nil
+		})
+	})
+
+	return names, err
+}
+
+// Quarantine copies the data of name, as stored, to a file in the
+// quarantine directory and removes it from the store.
+func (s *boltStorage) Quarantine(name string) error {
+	return s.update(func(b *bolt.Bucket) error {
+		data := b.Get([]byte(name))
+		if data == nil {
+			return fmt.Errorf("failed to quarantine %s: %w", name, os.ErrNotExist)
+		}
+
+		if err := os.MkdirAll(filepath.Join(s.dir, quarantineDir), 0755); err != nil {
+			return fmt.Errorf("failed to create quarantine directory: %w", err)
+		}
+		if err := writeFile(filepath.Join(s.dir, quarantineDir, name), data); err != nil {
+			return fmt.Errorf("failed to quarantine %s: %w", name, err)
+		}
+
+		return b.Delete([]byte(name))
+	})
+}
+
+// Close is a no-op: the database is only open during an operation.
+func (s *boltStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltStorage(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newBoltStorage(dir)
+	if err != nil {
+		t.Fatalf("newBoltStorage: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	if err := s.Write("a.bin", []byte("hello "), []byte("world")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := s.Write("b.bin", []byte("first")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := s.Write("b.bin", []byte("second")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if data, err := s.Read("a.bin"); err != nil || string(data) != "hello world" {
+		t.Errorf("Read: %q, %v", data, err)
+	}
+	if err := s.Remove("a.bin"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := s.Remove("missing.bin"); err != nil {
+		t.Fatalf("Remove of a missing name: %v", err)
+	}
+
+	if _, err := s.Read("a.bin"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist for a removed name, got %v", err)
+	}
+	if data, err := s.Read("b.bin"); err != nil || string(data) != "second" {
+		t.Errorf("Read: %q, %v", data, err)
+	}
+
+	// A second store on the same file sees the writes of the first, and the
+	// first sees the writes of the second.
+	other, err := newBoltStorage(dir)
+	if err != nil {
+		t.Fatalf("newBoltStorage: %v", err)
+	}
+	t.Cleanup(func() { other.Close() })
+	if names, err := other.List(); err != nil || len(names) != 1 || names[0] != "b.bin" {
+		t.Errorf("List: %v, %v", names, err)
+	}
+	if err := other.Write("c.bin", []byte("from other")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if data, err := s.Read("c.bin"); err != nil || string(data) != "from other" {
+		t.Errorf("Read of a name written by another store: %q, %v", data, err)
+	}
+}
+
+func TestBoltStorageQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newBoltStorage(dir)
+	if err != nil {
+		t.Fatalf("newBoltStorage: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+		if err := s.Write(name, []byte(name)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	if err := s.Quarantine("b.bin"); err != nil {
+		t.Fatalf("Quarantine: %v", err)
+	}
+	if names, err := s.List(); err != nil || len(names) != 2 || names[0] != "a.bin" || names[1] != "c.bin" {
+		t.Errorf("Expected a and c to be kept, got %v, %v", names, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, quarantineDir, "b.bin"))
+	if err != nil || !bytes.Equal(data, []byte("b.bin")) {
+		t.Errorf("Expected the quarantined data in the quarantine directory: %q, %v", data, err)
+	}
+
+	if err := s.Quarantine("missing.bin"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist for a missing name, got %v", err)
+	}
+}
+
+func TestCacheFileBackend(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, WithBackend(BackendFile), WithCompression(CompressionGzip))
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	if err := c.Set("scalar", "value"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := c.SetList("list", []interface{}{"a", "b"}); err != nil {
+		t.Fatalf("SetList: %v", err)
+	}
+	if err := c.SetList("gone", []interface{}{"x"}); err != nil {
+		t.Fatalf("SetList: %v", err)
+	}
+	if err := c.Delete("gone"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".bin" {
+			t.Errorf("Expected no entry files with the file backend, found %s", entry.Name())
+		}
+	}
+
+	c, err = New(dir, WithBackend(BackendFile))
+	if err != nil {
+		t.Fatalf("Failed to reopen cache: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("cache.Close: %v", err)
+		}
+	})
+
+	if value, ok, err := c.Get("scalar"); err != nil || !ok || value != "value" {
+		t.Errorf("Get: %v, %v, %v", value, ok, err)
+	}
+	if items, err := c.GetList("list"); err != nil || len(items) != 2 {
+		t.Errorf("GetList: %v, %v", items, err)
+	}
+	if _, err := c.GetList("gone"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected deleted entry to stay deleted, got %v", err)
+	}
+
+	report, err := c.Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if report.Verified != 2 || len(report.Corrupted) != 0 {
+		t.Errorf("Unexpected verify report: %+v", report)
+	}
+
+	if _, err := ParseBackend("sqlite"); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+}
@@ -1,17 +1,16 @@
 package cache
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/gob"
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 )
@@ -19,6 +18,11 @@ import (
 // ErrNotFound is returned when a requested key does not exist in the cache.
 var ErrNotFound = errors.New("key not found in cache")
 
+// ErrCorrupted is returned when a cache file is damaged: its checksum does
+// not match or it cannot be parsed. Corrupted entry files are moved to the
+// quarantine directory.
+var ErrCorrupted = errors.New("corrupted cache entry")
+
 func init() {
 	gob.Register("")
 	gob.Register([]interface{}{})
@@ -31,32 +35,67 @@ func init() {
 	gob.Register(time.Time{})
 }
 
-var httpClient = &http.Client{
-	Timeout: 30 * time.Second,
-}
-
 type EntryType string
 
 const (
-	TypeScalar EntryType = "scalar"
-	TypeList   EntryType = "list"
-	TypeWeb    EntryType = "web"
+	TypeScalar     EntryType = "scalar"
+	TypeList       EntryType = "list"
+	TypeWeb        EntryType = "web"
+	TypeScanResult EntryType = "scan_result"
 )
 
+// Metadata describes a cache entry. Size, AccessedAt and Pinned drive LRU
+// eviction; PinnedAt is when Pinned last changed, so that merging indexes
+// keeps the latest pin without rewriting the entry. Compression is the
+// codec of the entry file; entries written before compression was
+// supported have none. Checksum is the SHA-256 of the stored payload and
+// is empty for entries written before checksums were introduced. MaxAge,
+// ETag and LastModified are only used by web entries.
 type Metadata struct {
-	Type      EntryType
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	Type        EntryType
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	AccessedAt  time.Time
+	Size        int64
+	Pinned      bool
+	PinnedAt    time.Time
+	Compression Compression
+	Checksum    string
+
+	MaxAge       time.Duration
+	ETag         string
+	LastModified string
 }
 
+// RootIndex maps keys to their metadata. Version is the FormatVersion of
+// the cache layout; indexes written before it was introduced decode as 0.
 type RootIndex struct {
+	Version int
 	Entries map[string]Metadata
 }
 
 type Cache struct {
 	dir       string
+	storage   Storage
 	mu        sync.RWMutex
 	rootIndex *RootIndex
+
+	// base holds the UpdatedAt of every entry as last read from or written
+	// to disk, and removed the keys deleted by this process since then.
+	// Both drive the merge in saveRootIndex.
+	base    map[string]time.Time
+	removed map[string]time.Time
+
+	directoryReport DirectoryReport
+
+	maxBytes    int64
+	maxEntries  int
+	compression Compression
+	backend     Backend
+	offline     bool
+
+	// closed is set by Close and Clear; closing again is a no-op.
+	closed bool
 }
 
 // Dir returns the cache directory path.
@@ -69,108 +108,113 @@ func (c *Cache) hashKey(key string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-func (c *Cache) getFilePath(key string) string {
-	hashedKey := c.hashKey(key)
-	return filepath.Join(c.dir, hashedKey+".bin")
+// entryName returns the storage name of the file of key.
+func (c *Cache) entryName(key string) string {
+	return c.hashKey(key) + ".bin"
 }
 
-func (c *Cache) getRootIndexPath() string {
-	return filepath.Join(c.dir, "root.index.bin")
+func (c *Cache) saveToFile(name string, data interface{}, compression Compression) error {
+	payload, err := encode(data, compression)
+	if err != nil {
+		return fmt.Errorf("failed to encode data to %s: %w", name, err)
+	}
+
+	return c.storage.Write(name, payload)
 }
 
-func (c *Cache) saveToFile(filePath string, data interface{}) error {
-	tmpPath := filePath + ".tmp"
-	file, err := os.Create(tmpPath)
+// loadFromFile decodes the stored file name into target. A missing file
+// leaves target untouched; undecodable content is reported as ErrCorrupted.
+func (c *Cache) loadFromFile(name string, target interface{}, compression Compression) error {
+	data, err := c.storage.Read(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create temp file %s: %w", tmpPath, err)
+		return err
+	}
+
+	if err := decode(data, target, compression); err != nil {
+		return fmt.Errorf("%w: failed to decode data from %s: %v", ErrCorrupted, name, err)
 	}
 
-	encoder := gob.NewEncoder(file)
-	if err := encoder.Encode(data); err != nil {
-		file.Close()
-		os.Remove(tmpPath)
-		return fmt.Errorf("failed to encode data to file %s: %w", filePath, err)
+	return nil
+}
+
+// encode gob-encodes data and compresses the result.
+func encode(data interface{}, compression Compression) ([]byte, error) {
+	var buf bytes.Buffer
+	w, compressor, err := compressWriter(&buf, compression)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := file.Sync(); err != nil {
-		file.Close()
-		os.Remove(tmpPath)
-		return fmt.Errorf("failed to sync temp file %s: %w", tmpPath, err)
+	if err := gob.NewEncoder(w).Encode(data); err != nil {
+		return nil, err
 	}
 
-	if err := file.Close(); err != nil {
-		os.Remove(tmpPath)
-		return fmt.Errorf("failed to close temp file %s: %w", tmpPath, err)
+	if err := compressor.Close(); err != nil {
+		return nil, err
 	}
 
-	return os.Rename(tmpPath, filePath)
+	return buf.Bytes(), nil
 }
 
-func (c *Cache) loadFromFile(filePath string, target interface{}) error {
-	file, err := os.Open(filePath)
+// decode reverses encode.
+func decode(payload []byte, target interface{}, compression Compression) error {
+	r, err := decompressReader(bytes.NewReader(payload), compression)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
-		return fmt.Errorf("failed to open file %s: %w", filePath, err)
+		return err
 	}
-	defer file.Close()
 
-	decoder := gob.NewDecoder(file)
-	if err := decoder.Decode(target); err != nil {
-		return fmt.Errorf("failed to decode data from file %s: %w", filePath, err)
-	}
+	return gob.NewDecoder(r).Decode(target)
+}
 
-	return nil
+// DirectoryReport lists the inconsistencies found when the cache storage
+// was scanned on open: files without an index entry, and index entries
+// whose file was missing and which were therefore dropped.
+type DirectoryReport struct {
+	Orphaned []string
+	Dangling []string
 }
 
 func (c *Cache) scanDirectory() error {
-	entries, err := os.ReadDir(c.dir)
+	c.directoryReport = DirectoryReport{}
+
+	names, err := c.storage.List()
 	if err != nil {
-		return fmt.Errorf("failed to read cache directory: %w", err)
+		return err
 	}
 
-	diskFiles := make(map[string]bool)
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			diskFiles[entry.Name()] = true
-		}
+	stored := make(map[string]bool, len(names))
+	for _, name := range names {
+		stored[name] = true
 	}
 
-	expectedFiles := make(map[string]bool, len(c.rootIndex.Entries))
+	expected := make(map[string]bool, len(c.rootIndex.Entries))
 	for key := range c.rootIndex.Entries {
-		expectedFiles[c.hashKey(key)+".bin"] = true
+		expected[c.entryName(key)] = true
 	}
 
-	for filename := range diskFiles {
-		if filename == "root.index.bin" {
-			continue
-		}
-
-		if strings.HasSuffix(filename, ".tmp") {
-			if err := os.Remove(filepath.Join(c.dir, filename)); err != nil {
-				log.Printf("warning: failed to remove stale tmp file %s: %v", filename, err)
-			}
-			continue
-		}
-
-		if !expectedFiles[filename] {
-			log.Printf("warning: orphaned cache file found: %s", filename)
+	for _, name := range names {
+		if name != rootIndexFile && !expected[name] {
+			log.Printf("warning: orphaned cache file found: %s", name)
+			c.directoryReport.Orphaned = append(c.directoryReport.Orphaned, name)
 		}
 	}
 
 	keysToRemove := []string{}
 	for key := range c.rootIndex.Entries {
-		filePath := c.getFilePath(key)
-		if _, exists := diskFiles[filepath.Base(filePath)]; !exists {
-			log.Printf("warning: cache entry %s referenced in index but file not found: %s", key, filePath)
+		if name := c.entryName(key); !stored[name] {
+			log.Printf("warning: cache entry %s referenced in index but file not found: %s", key, name)
 			keysToRemove = append(keysToRemove, key)
 		}
 	}
 
 	for _, key := range keysToRemove {
-		delete(c.rootIndex.Entries, key)
+		c.forget(key)
 	}
+	sort.Strings(keysToRemove)
+	c.directoryReport.Dangling = keysToRemove
 
 	if len(keysToRemove) > 0 {
 		if err := c.saveRootIndex(); err != nil {
@@ -181,56 +225,56 @@ func (c *Cache) scanDirectory() error {
 	return nil
 }
 
-func (c *Cache) loadRootIndex() error {
-	rootIndexPath := c.getRootIndexPath()
+// put writes data for key and records it in the root index with the given
+// metadata, filling in the timestamps. The file and the index are written
+// under the cross-process lock, so another process writing the same key
+// cannot leave the index describing a different file. The caller must hold
+// the write lock.
+func (c *Cache) put(key string, metadata Metadata, data interface{}) error {
+	return c.withIndexLock(func() error {
+		return c.putLocked(key, metadata, data)
+	})
+}
 
-	index := &RootIndex{
-		Entries: make(map[string]Metadata),
+func (c *Cache) putLocked(key string, metadata Metadata, data interface{}) error {
+	now := time.Now()
+	metadata.CreatedAt = now
+	if existing, ok := c.rootIndex.Entries[key]; ok {
+		metadata.CreatedAt = existing.CreatedAt
+		metadata.Pinned = existing.Pinned
+		metadata.PinnedAt = existing.PinnedAt
 	}
+	metadata.UpdatedAt = now
+	metadata.AccessedAt = now
+	metadata.Compression = c.compression
 
-	if err := c.loadFromFile(rootIndexPath, index); err != nil {
+	metadata, err := c.saveEntry(key, metadata, data)
+	if err != nil {
 		return err
 	}
+	c.rootIndex.Entries[key] = metadata
 
-	c.rootIndex = index
-	return nil
-}
+	if err := c.evict(key); err != nil {
+		return err
+	}
 
-func (c *Cache) saveRootIndex() error {
-	rootIndexPath := c.getRootIndexPath()
-	return c.saveToFile(rootIndexPath, c.rootIndex)
+	return c.syncRootIndex()
 }
 
 func (c *Cache) Set(key string, value interface{}) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	filePath := c.getFilePath(key)
-
 	wrapper := map[string]interface{}{
 		"data": value,
 	}
 
-	if err := c.saveToFile(filePath, wrapper); err != nil {
-		return err
-	}
-
-	createdAt := time.Now()
-	if existing, ok := c.rootIndex.Entries[key]; ok {
-		createdAt = existing.CreatedAt
-	}
-	c.rootIndex.Entries[key] = Metadata{
-		Type:      TypeScalar,
-		CreatedAt: createdAt,
-		UpdatedAt: time.Now(),
-	}
-
-	return c.saveRootIndex()
+	return c.put(key, Metadata{Type: TypeScalar}, wrapper)
 }
 
 func (c *Cache) Get(key string) (interface{}, bool, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	metadata, exists := c.rootIndex.Entries[key]
 	if !exists {
@@ -241,9 +285,8 @@ func (c *Cache) Get(key string) (interface{}, bool, error) {
 		return nil, false, fmt.Errorf("key %s is not a scalar entry", key)
 	}
 
-	filePath := c.getFilePath(key)
 	wrapper := make(map[string]interface{})
-	if err := c.loadFromFile(filePath, &wrapper); err != nil {
+	if err := c.loadEntry(key, metadata, &wrapper); err != nil {
 		return nil, false, err
 	}
 
@@ -251,6 +294,7 @@ func (c *Cache) Get(key string) (interface{}, bool, error) {
 	if !exists {
 		return nil, false, fmt.Errorf("data field missing in cached entry for key %s", key)
 	}
+	c.touch(key)
 
 	return value, true, nil
 }
@@ -259,27 +303,12 @@ func (c *Cache) SetList(key string, items []interface{}) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	filePath := c.getFilePath(key)
-	if err := c.saveToFile(filePath, items); err != nil {
-		return err
-	}
-
-	createdAt := time.Now()
-	if existing, ok := c.rootIndex.Entries[key]; ok {
-		createdAt = existing.CreatedAt
-	}
-	c.rootIndex.Entries[key] = Metadata{
-		Type:      TypeList,
-		CreatedAt: createdAt,
-		UpdatedAt: time.Now(),
-	}
-
-	return c.saveRootIndex()
+	return c.put(key, Metadata{Type: TypeList}, items)
 }
 
 func (c *Cache) GetList(key string) ([]interface{}, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	metadata, exists := c.rootIndex.Entries[key]
 	if !exists {
@@ -290,95 +319,44 @@ func (c *Cache) GetList(key string) ([]interface{}, error) {
 		return nil, fmt.Errorf("key %s is not a list entry", key)
 	}
 
-	filePath := c.getFilePath(key)
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
-	}
-
 	var items []interface{}
-	if err := c.loadFromFile(filePath, &items); err != nil {
+	if err := c.loadEntry(key, metadata, &items); err != nil {
 		return nil, err
 	}
+	c.touch(key)
 
 	return items, nil
 }
 
-func (c *Cache) GetWeb(url string) ([]byte, error) {
-	key := url
-
-	c.mu.RLock()
-	metadata, exists := c.rootIndex.Entries[key]
-	if exists && metadata.Type == TypeWeb {
-		filePath := c.getFilePath(key)
-		var content []byte
-		err := c.loadFromFile(filePath, &content)
-		c.mu.RUnlock()
-		if err != nil {
-			return nil, err
-		}
-		if content == nil {
-			return nil, fmt.Errorf("cached data missing for key %s", key)
-		}
-		return content, nil
-	}
-	c.mu.RUnlock()
-
-	resp, err := httpClient.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to download from %s: %w", url, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to download from %s: status code %d", url, resp.StatusCode)
-	}
-
-	content, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
+func (c *Cache) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Double-check: another goroutine may have cached this while we were fetching.
-	if existing, exists := c.rootIndex.Entries[key]; exists && existing.Type == TypeWeb {
-		filePath := c.getFilePath(key)
-		var cached []byte
-		if err := c.loadFromFile(filePath, &cached); err == nil && cached != nil {
-			return cached, nil
-		}
-	}
-
-	filePath := c.getFilePath(key)
-	if err := c.saveToFile(filePath, content); err != nil {
-		return nil, err
+	if c.closed {
+		return nil
 	}
+	c.closed = true
 
-	createdAt := time.Now()
-	if existing, ok := c.rootIndex.Entries[key]; ok {
-		createdAt = existing.CreatedAt
-	}
-	c.rootIndex.Entries[key] = Metadata{
-		Type:      TypeWeb,
-		CreatedAt: createdAt,
-		UpdatedAt: time.Now(),
+	log.Println("Saving root index before exit...")
+	if err := c.saveRootIndex(); err != nil {
+		c.storage.Close()
+		return err
 	}
 
-	return content, c.saveRootIndex()
-}
-
-func (c *Cache) Close() error {
-	log.Println("Saving root index before exit...")
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	return c.saveRootIndex()
+	return c.storage.Close()
 }
 
 func (c *Cache) Clear() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if !c.closed {
+		c.closed = true
+		if err := c.storage.Close(); err != nil {
+			log.Printf("warning: failed to close cache storage: %v", err)
+		}
+	}
+
 	if err := os.RemoveAll(c.dir); err != nil {
 		return fmt.Errorf("failed to remove cache directory: %w", err)
 	}
@@ -387,7 +365,7 @@ func (c *Cache) Clear() error {
 	return nil
 }
 
-func New(cacheDir string) (*Cache, error) {
+func New(cacheDir string, opts ...Option) (*Cache, error) {
 	if cacheDir == "" {
 		return nil, fmt.Errorf("cache directory cannot be empty")
 	}
@@ -418,14 +396,38 @@ func New(cacheDir string) (*Cache, error) {
 	c := &Cache{
 		dir: dir,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	storage, err := openStorage(dir, c.backend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache storage: %w", err)
+	}
+	c.storage = storage
+
+	if err := c.load(); err != nil {
+		storage.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// load removes interrupted writes, reads the root index, picks up entries
+// missing from it and migrates it to the current format.
+func (c *Cache) load() error {
+	if err := c.withIndexLock(c.removeTempFiles); err != nil {
+		return err
+	}
 
 	if err := c.loadRootIndex(); err != nil {
-		return nil, fmt.Errorf("failed to load root index: %w", err)
+		return fmt.Errorf("failed to load root index: %w", err)
 	}
 
 	if err := c.scanDirectory(); err != nil {
-		return nil, fmt.Errorf("failed to scan cache directory: %w", err)
+		return fmt.Errorf("failed to scan cache directory: %w", err)
 	}
 
-	return c, nil
+	return c.migrate()
 }
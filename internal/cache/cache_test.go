@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -256,3 +259,866 @@ func TestCacheGetWeb(t *testing.T) {
 		t.Errorf("Expected still 1 HTTP request after cache hit, got %d", requestCount.Load())
 	}
 }
+
+func TestCacheTyped(t *testing.T) {
+	type measurement struct {
+		Address string
+		Latency time.Duration
+		Stages  map[string]time.Duration
+	}
+
+	tmpDir := t.TempDir()
+	c, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	want := []measurement{
+		{Address: "1.2.3.4:1080", Latency: 120 * time.Millisecond, Stages: map[string]time.Duration{"dial": time.Millisecond}},
+		{Address: "5.6.7.8:1080", Latency: 80 * time.Millisecond},
+	}
+	if err := SetTyped(c, "scan/socks5/1", TypeScanResult, want); err != nil {
+		t.Fatalf("SetTyped: %v", err)
+	}
+	if err := SetTyped(c, "scan/socks5/2", TypeScanResult, want[:1]); err != nil {
+		t.Fatalf("SetTyped: %v", err)
+	}
+	if err := c.Set("scan/socks5/scalar", "not a scan"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+
+	c, err = New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen cache: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("cache.Close: %v", err)
+		}
+	})
+
+	keys := c.Keys("scan/socks5/", TypeScanResult)
+	if len(keys) != 2 || keys[0] != "scan/socks5/1" || keys[1] != "scan/socks5/2" {
+		t.Fatalf("Keys: expected [scan/socks5/1 scan/socks5/2], got %v", keys)
+	}
+
+	got, err := GetTyped[[]measurement](c, "scan/socks5/1", TypeScanResult)
+	if err != nil {
+		t.Fatalf("GetTyped: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d measurements, got %d", len(want), len(got))
+	}
+	if got[0].Address != want[0].Address || got[0].Latency != want[0].Latency || got[0].Stages["dial"] != time.Millisecond {
+		t.Errorf("Expected %+v, got %+v", want[0], got[0])
+	}
+
+	if _, err := GetTyped[[]measurement](c, "scan/socks5/missing", TypeScanResult); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetTyped on missing key: expected ErrNotFound, got %v", err)
+	}
+	if _, err := GetTyped[[]measurement](c, "scan/socks5/scalar", TypeScanResult); err == nil {
+		t.Error("GetTyped on scalar key: expected error, got nil")
+	}
+}
+
+func TestCacheGetWebRevalidate(t *testing.T) {
+	var requestCount, notModified atomic.Int32
+	var body atomic.Value
+	body.Store("v1")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount.Add(1)
+		etag := `"` + body.Load().(string) + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprint(w, body.Load().(string))
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("cache.Close: %v", err)
+		}
+	})
+
+	get := func(want string, opts ...WebOption) {
+		t.Helper()
+		content, err := c.GetWeb(srv.URL, opts...)
+		if err != nil {
+			t.Fatalf("GetWeb: %v", err)
+		}
+		if string(content) != want {
+			t.Fatalf("GetWeb: expected %q, got %q", want, string(content))
+		}
+	}
+
+	// Miss: downloads and stores the ETag and max age.
+	get("v1", WithMaxAge(time.Hour))
+	if requestCount.Load() != 1 {
+		t.Fatalf("Expected 1 request after miss, got %d", requestCount.Load())
+	}
+	first, _ := c.Metadata(srv.URL)
+	if first.ETag != `"v1"` || first.MaxAge != time.Hour {
+		t.Fatalf("Expected stored ETag and max age, got %+v", first)
+	}
+
+	// Fresh: served from cache using the stored max age.
+	get("v1")
+	if requestCount.Load() != 1 {
+		t.Fatalf("Expected fresh entry to be served from cache, got %d requests", requestCount.Load())
+	}
+
+	// Forced: revalidated, 304 keeps content and bumps UpdatedAt.
+	get("v1", WithForceRefresh())
+	if notModified.Load() != 1 {
+		t.Fatalf("Expected a 304 revalidation, got %d", notModified.Load())
+	}
+	revalidated, _ := c.Metadata(srv.URL)
+	if !revalidated.UpdatedAt.After(first.UpdatedAt) {
+		t.Errorf("Expected UpdatedAt to be bumped by 304, got %s (was %s)", revalidated.UpdatedAt, first.UpdatedAt)
+	}
+	if !revalidated.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("Expected CreatedAt to be kept, got %s (was %s)", revalidated.CreatedAt, first.CreatedAt)
+	}
+
+	// Expired: content changed upstream, so the new body replaces the old one.
+	body.Store("v2")
+	time.Sleep(5 * time.Millisecond)
+	get("v2", WithMaxAge(time.Millisecond))
+	if requestCount.Load() != 3 {
+		t.Fatalf("Expected expired entry to be downloaded again, got %d requests", requestCount.Load())
+	}
+	updated, _ := c.Metadata(srv.URL)
+	if updated.ETag != `"v2"` {
+		t.Errorf("Expected ETag to be updated to \"v2\", got %s", updated.ETag)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("cache.Close: %v", err)
+		}
+	})
+
+	for _, key := range []string{"scan/a/1", "scan/a/2", "scan/b/1"} {
+		if err := SetTyped(c, key, TypeScanResult, key); err != nil {
+			t.Fatalf("SetTyped %s: %v", key, err)
+		}
+	}
+	if err := c.SetList("list", []interface{}{"x"}); err != nil {
+		t.Fatalf("SetList: %v", err)
+	}
+	if err := c.Set("old", "value"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Age the scalar entry on disk too, so that merging does not undo it
+	// and pruning picks it up.
+	c.mu.Lock()
+	old := c.rootIndex.Entries["old"]
+	old.UpdatedAt = time.Now().Add(-48 * time.Hour)
+	c.rootIndex.Entries["old"] = old
+	if err := c.saveToFile(rootIndexFile, c.rootIndex, CompressionNone); err != nil {
+		t.Fatalf("Failed to write aged root index: %v", err)
+	}
+	c.mu.Unlock()
+
+	if err := c.Delete("list"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := c.GetList("list"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetList after Delete: expected ErrNotFound, got %v", err)
+	}
+	if err := c.Delete("list"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete of missing key: expected ErrNotFound, got %v", err)
+	}
+
+	if n, err := c.Purge(Filter{OlderThan: 24 * time.Hour}); err != nil || n != 1 {
+		t.Fatalf("Purge by age: expected 1 removed, got %d (%v)", n, err)
+	}
+	if _, ok := c.Metadata("old"); ok {
+		t.Error("Expected pruned entry to be gone")
+	}
+
+	if n, err := c.Purge(Filter{Prefix: "scan/a/", Type: TypeScanResult}); err != nil || n != 2 {
+		t.Fatalf("Purge by prefix: expected 2 removed, got %d (%v)", n, err)
+	}
+	if n, err := c.Purge(Filter{Type: TypeWeb}); err != nil || n != 0 {
+		t.Fatalf("Purge by type: expected 0 removed, got %d (%v)", n, err)
+	}
+
+	keys := c.Keys("", "")
+	if len(keys) != 1 || keys[0] != "scan/b/1" {
+		t.Errorf("Expected only scan/b/1 to survive, got %v", keys)
+	}
+
+	entries, err := os.ReadDir(c.Dir())
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("Expected root index, lock file and one entry file on disk, got %d files", len(entries))
+	}
+}
+
+func TestCacheIndexMerge(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	a, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache a: %v", err)
+	}
+	b, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache b: %v", err)
+	}
+
+	if err := a.Set("shared", "from-a"); err != nil {
+		t.Fatalf("a.Set: %v", err)
+	}
+	if err := b.Set("only-b", "from-b"); err != nil {
+		t.Fatalf("b.Set: %v", err)
+	}
+	if _, ok := b.Metadata("shared"); !ok {
+		t.Error("Expected b to pick up a's entry when saving its index")
+	}
+
+	if err := b.Delete("shared"); err != nil {
+		t.Fatalf("b.Delete: %v", err)
+	}
+	if err := a.Set("only-a", "from-a"); err != nil {
+		t.Fatalf("a.Set: %v", err)
+	}
+	if _, ok := a.Metadata("shared"); ok {
+		t.Error("Expected a not to resurrect the entry deleted by b")
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatalf("a.Close: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("b.Close: %v", err)
+	}
+
+	c, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen cache: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("cache.Close: %v", err)
+		}
+	})
+
+	keys := c.Keys("", "")
+	if len(keys) != 2 || keys[0] != "only-a" || keys[1] != "only-b" {
+		t.Errorf("Expected [only-a only-b] after merging, got %v", keys)
+	}
+}
+
+func TestCacheReload(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	a, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache a: %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+	b, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache b: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+
+	if err := b.Set("from-b", "value"); err != nil {
+		t.Fatalf("b.Set: %v", err)
+	}
+	if _, ok := a.Metadata("from-b"); ok {
+		t.Fatal("Expected a not to see b's entry before reloading")
+	}
+
+	if err := a.Reload(); err != nil {
+		t.Fatalf("a.Reload: %v", err)
+	}
+	if value, ok, err := a.Get("from-b"); err != nil || !ok || value != "value" {
+		t.Errorf("Expected b's entry after reloading, got %v, %v, %v", value, ok, err)
+	}
+
+	if err := b.Delete("from-b"); err != nil {
+		t.Fatalf("b.Delete: %v", err)
+	}
+	if err := a.Reload(); err != nil {
+		t.Fatalf("a.Reload: %v", err)
+	}
+	if _, ok := a.Metadata("from-b"); ok {
+		t.Error("Expected the entry deleted by b to be gone after reloading")
+	}
+}
+
+func TestCacheSameKeyAcrossProcesses(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	a, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache a: %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+	b, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache b: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+
+	if err := a.Set("shared", "from-a"); err != nil {
+		t.Fatalf("a.Set: %v", err)
+	}
+	// Make sure b's write is newer even with a coarse clock.
+	time.Sleep(10 * time.Millisecond)
+	if err := b.Set("shared", "from-b"); err != nil {
+		t.Fatalf("b.Set: %v", err)
+	}
+
+	// a still has its own write in memory, but the file holds b's.
+	value, ok, err := a.Get("shared")
+	if err != nil || !ok || value != "from-b" {
+		t.Fatalf("Expected a to read b's write, got %v, %v, %v", value, ok, err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, quarantineDir)); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be quarantined, got %v", err)
+	}
+
+	report, err := a.Verify()
+	if err != nil {
+		t.Fatalf("a.Verify: %v", err)
+	}
+	if report.Verified != 1 || len(report.Corrupted) != 0 {
+		t.Errorf("Expected the entry to verify, got %+v", report)
+	}
+}
+
+func TestCacheDirectoryReport(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	c, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	if err := c.Set("kept", "value"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := c.Set("lost", "value"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	info, err := c.Inspect("kept")
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if info.Size == 0 || len(info.SHA256) != 64 || info.File != c.hashKey("kept")+".bin" {
+		t.Errorf("Unexpected entry info: %+v", info)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(c.Dir(), c.entryName("lost"))); err != nil {
+		t.Fatalf("Failed to remove entry file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "orphan.bin"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write orphan file: %v", err)
+	}
+
+	c, err = New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen cache: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("cache.Close: %v", err)
+		}
+	})
+
+	report := c.DirectoryReport()
+	if len(report.Orphaned) != 1 || report.Orphaned[0] != "orphan.bin" {
+		t.Errorf("Expected orphan.bin to be reported, got %v", report.Orphaned)
+	}
+	if len(report.Dangling) != 1 || report.Dangling[0] != "lost" {
+		t.Errorf("Expected dangling entry lost to be reported, got %v", report.Dangling)
+	}
+
+	entries := c.Entries()
+	if len(entries) != 1 || entries[0].Key != "kept" {
+		t.Errorf("Expected only kept to be listed, got %+v", entries)
+	}
+}
+
+func TestCacheLRUEviction(t *testing.T) {
+	c, err := New(t.TempDir(), WithMaxEntries(2))
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("cache.Close: %v", err)
+		}
+	})
+
+	set := func(key string) {
+		t.Helper()
+		if err := c.Set(key, key); err != nil {
+			t.Fatalf("Set %s: %v", key, err)
+		}
+		// Keep access times strictly ordered.
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	set("a")
+	set("b")
+	if _, _, err := c.Get("a"); err != nil {
+		t.Fatalf("Get a: %v", err)
+	}
+	set("c")
+
+	if keys := c.Keys("", ""); len(keys) != 2 || keys[0] != "a" || keys[1] != "c" {
+		t.Fatalf("Expected least recently used b to be evicted, got %v", keys)
+	}
+
+	if err := c.Pin("a", true); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	set("d")
+	if keys := c.Keys("", ""); len(keys) != 2 || keys[0] != "a" || keys[1] != "d" {
+		t.Fatalf("Expected pinned a to survive eviction, got %v", keys)
+	}
+
+	metadata, _ := c.Metadata("d")
+	if metadata.Size == 0 || metadata.AccessedAt.IsZero() {
+		t.Errorf("Expected size and access time to be tracked, got %+v", metadata)
+	}
+}
+
+func TestCachePinAndAccessAcrossProcesses(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	a, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache a: %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+	if err := a.Set("pinned", "value"); err != nil {
+		t.Fatalf("a.Set: %v", err)
+	}
+	if err := a.Set("read", "value"); err != nil {
+		t.Fatalf("a.Set: %v", err)
+	}
+
+	b, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache b: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+
+	// a pins one entry and reads the other; b, with the older index in
+	// memory, then saves its own.
+	if err := a.Pin("pinned", true); err != nil {
+		t.Fatalf("a.Pin: %v", err)
+	}
+	if _, _, err := a.Get("read"); err != nil {
+		t.Fatalf("a.Get: %v", err)
+	}
+	accessedAt := a.rootIndex.Entries["read"].AccessedAt
+	if err := a.saveRootIndex(); err != nil {
+		t.Fatalf("a.saveRootIndex: %v", err)
+	}
+	if err := b.Set("other", "value"); err != nil {
+		t.Fatalf("b.Set: %v", err)
+	}
+
+	if m, _ := b.Metadata("pinned"); !m.Pinned {
+		t.Error("Expected b to keep the pin set by a")
+	}
+	if m, _ := b.Metadata("read"); !m.AccessedAt.Equal(accessedAt) {
+		t.Errorf("Expected b to keep the access by a at %v, got %v", accessedAt, m.AccessedAt)
+	}
+
+	// Unpinning in b is newer than the pin in a and wins.
+	if err := b.Pin("pinned", false); err != nil {
+		t.Fatalf("b.Pin: %v", err)
+	}
+	if err := a.Reload(); err != nil {
+		t.Fatalf("a.Reload: %v", err)
+	}
+	if m, _ := a.Metadata("pinned"); m.Pinned {
+		t.Error("Expected a to see the entry unpinned by b")
+	}
+}
+
+func TestCacheMaxBytes(t *testing.T) {
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	if err := c.SetList("first", []interface{}{string(make([]byte, 1000))}); err != nil {
+		t.Fatalf("SetList: %v", err)
+	}
+	metadata, _ := c.Metadata("first")
+	if err := c.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+
+	// Room for one entry only.
+	c, err = New(c.Dir(), WithMaxBytes(metadata.Size+metadata.Size/2))
+	if err != nil {
+		t.Fatalf("Failed to reopen cache: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("cache.Close: %v", err)
+		}
+	})
+
+	if err := c.SetList("second", []interface{}{string(make([]byte, 1000))}); err != nil {
+		t.Fatalf("SetList: %v", err)
+	}
+	if keys := c.Keys("", ""); len(keys) != 1 || keys[0] != "second" {
+		t.Errorf("Expected first to be evicted to stay under max bytes, got %v", keys)
+	}
+}
+
+func TestCacheCompression(t *testing.T) {
+	dir := t.TempDir()
+	payload := strings.Repeat("127.0.0.1:1080\n", 200)
+
+	// Written before compression was enabled.
+	c, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	if err := c.Set("legacy", payload); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	legacy, _ := c.Metadata("legacy")
+	if legacy.Compression != CompressionNone {
+		t.Errorf("Expected no compression by default, got %q", legacy.Compression)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+
+	c, err = New(dir, WithCompression(CompressionGzip))
+	if err != nil {
+		t.Fatalf("Failed to reopen cache: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("cache.Close: %v", err)
+		}
+	})
+
+	if err := c.Set("compressed", payload); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	compressed, _ := c.Metadata("compressed")
+	if compressed.Compression != CompressionGzip {
+		t.Errorf("Expected gzip compression, got %q", compressed.Compression)
+	}
+	if compressed.Size >= legacy.Size {
+		t.Errorf("Expected compressed entry to be smaller: %d >= %d", compressed.Size, legacy.Size)
+	}
+
+	for _, key := range []string{"legacy", "compressed"} {
+		value, ok, err := c.Get(key)
+		if err != nil || !ok {
+			t.Fatalf("Get %s: ok=%v err=%v", key, ok, err)
+		}
+		if value != payload {
+			t.Errorf("Get %s returned a different payload", key)
+		}
+	}
+
+	if _, err := ParseCompression("zstd"); err == nil {
+		t.Error("Expected error for unsupported compression")
+	}
+}
+
+func TestCacheIntegrity(t *testing.T) {
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("cache.Close: %v", err)
+		}
+	})
+
+	for _, key := range []string{"good", "bad", "truncated"} {
+		if err := c.SetList(key, []interface{}{key, 1, 2, 3}); err != nil {
+			t.Fatalf("SetList %s: %v", key, err)
+		}
+	}
+	if metadata, _ := c.Metadata("good"); len(metadata.Checksum) != 64 {
+		t.Errorf("Expected a SHA-256 checksum, got %q", metadata.Checksum)
+	}
+
+	badPath := filepath.Join(c.Dir(), c.entryName("bad"))
+	data, err := os.ReadFile(badPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(badPath, data, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Truncate(filepath.Join(c.Dir(), c.entryName("truncated")), int64(len(data)/2)); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+
+	if _, err := c.GetList("bad"); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("Expected ErrCorrupted, got %v", err)
+	}
+	if _, ok := c.Metadata("bad"); ok {
+		t.Error("Expected corrupted entry to be dropped from the index")
+	}
+	if _, err := os.Stat(filepath.Join(c.Dir(), quarantineDir, filepath.Base(badPath))); err != nil {
+		t.Errorf("Expected corrupted file in quarantine: %v", err)
+	}
+
+	report, err := c.Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if report.Verified != 1 || len(report.Corrupted) != 1 || report.Corrupted[0] != "truncated" {
+		t.Errorf("Unexpected verify report: %+v", report)
+	}
+
+	// Lose the index: every surviving file is orphaned until rebuilt.
+	if err := os.Remove(filepath.Join(c.Dir(), rootIndexFile)); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	c, err = New(c.Dir())
+	if err != nil {
+		t.Fatalf("Failed to reopen cache: %v", err)
+	}
+	if keys := c.Keys("", ""); len(keys) != 0 {
+		t.Fatalf("Expected an empty index, got %v", keys)
+	}
+	if orphaned := c.DirectoryReport().Orphaned; len(orphaned) != 1 {
+		t.Fatalf("Expected one orphaned file, got %v", orphaned)
+	}
+
+	rebuilt, err := c.RebuildIndex()
+	if err != nil {
+		t.Fatalf("RebuildIndex: %v", err)
+	}
+	if len(rebuilt.Recovered) != 1 || rebuilt.Recovered[0] != "good" {
+		t.Errorf("Unexpected rebuild report: %+v", rebuilt)
+	}
+	items, err := c.GetList("good")
+	if err != nil || len(items) != 4 || items[0] != "good" {
+		t.Errorf("GetList after rebuild: %v, %v", items, err)
+	}
+}
+
+func TestCacheCorruptedIndex(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	if err := c.Set("key", "value"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(c.Dir(), rootIndexFile), []byte("not a gob stream"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	c, err = New(dir)
+	if err != nil {
+		t.Fatalf("Expected a corrupted index to be quarantined, got %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("cache.Close: %v", err)
+		}
+	})
+	if _, err := os.Stat(filepath.Join(dir, quarantineDir, rootIndexFile)); err != nil {
+		t.Errorf("Expected corrupted index in quarantine: %v", err)
+	}
+
+	if _, err := c.RebuildIndex(); err != nil {
+		t.Fatalf("RebuildIndex: %v", err)
+	}
+	if value, ok, err := c.Get("key"); err != nil || !ok || value != "value" {
+		t.Errorf("Get after rebuild: %v, %v, %v", value, ok, err)
+	}
+}
+
+// copyFixture copies a cache directory from testdata into a temporary
+// directory, so tests can open and migrate it.
+func copyFixture(t *testing.T, name string) string {
+	t.Helper()
+
+	src := filepath.Join("testdata", name)
+	dst := t.TempDir()
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(src, entry.Name()))
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dst, entry.Name()), data, 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	return dst
+}
+
+func TestCacheMigrations(t *testing.T) {
+	if len(migrations) != FormatVersion {
+		t.Fatalf("Expected %d migrations, got %d", FormatVersion, len(migrations))
+	}
+	for i, m := range migrations {
+		if m.From != i {
+			t.Errorf("Migration %d upgrades from version %d", i, m.From)
+		}
+	}
+
+	// testdata/v<N> holds a cache written by version N of the format. Add a
+	// fixture whenever FormatVersion is bumped.
+	for version := 0; version <= FormatVersion; version++ {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			c, err := New(copyFixture(t, fmt.Sprintf("v%d", version)))
+			if err != nil {
+				t.Fatalf("Failed to open fixture: %v", err)
+			}
+			t.Cleanup(func() {
+				if err := c.Close(); err != nil {
+					t.Errorf("cache.Close: %v", err)
+				}
+			})
+
+			if c.rootIndex.Version != FormatVersion {
+				t.Errorf("Expected version %d after migration, got %d", FormatVersion, c.rootIndex.Version)
+			}
+
+			value, ok, err := c.Get("scalar")
+			if err != nil || !ok || value != "hello" {
+				t.Errorf("Get: %v, %v, %v", value, ok, err)
+			}
+			items, err := c.GetList("list")
+			if err != nil || len(items) != 3 || items[0] != "a" || items[1] != 1 || items[2] != 2.5 {
+				t.Errorf("GetList: %v, %v", items, err)
+			}
+			content, err := c.GetWeb("http://127.0.0.1:19555/list.txt")
+			if err != nil || string(content) != "127.0.0.1:1080\n" {
+				t.Errorf("GetWeb: %q, %v", content, err)
+			}
+
+			report, err := c.Verify()
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if report.Verified != 3 || len(report.Unverified) != 0 {
+				t.Errorf("Expected every entry to carry a checksum: %+v", report)
+			}
+		})
+	}
+}
+
+func TestCacheNewerFormat(t *testing.T) {
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	c.rootIndex.Version = FormatVersion + 1
+	if err := c.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+
+	if _, err := New(c.Dir()); err == nil {
+		t.Error("Expected an error for a cache written by a newer version")
+	}
+}
+
+func TestCacheGetWebStaleAndOffline(t *testing.T) {
+	var requestCount atomic.Int32
+	var failing atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount.Add(1)
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "list")
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	c, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	if _, err := c.GetWeb(srv.URL, WithMaxAge(time.Nanosecond)); err != nil {
+		t.Fatalf("GetWeb: %v", err)
+	}
+
+	// The cached copy is expired and the source is down.
+	failing.Store(true)
+	if _, err := c.GetWeb(srv.URL); err == nil {
+		t.Error("Expected an error without stale fallback")
+	}
+	content, err := c.GetWeb(srv.URL, WithStaleIfError())
+	if err != nil || string(content) != "list" {
+		t.Errorf("Expected the stale copy, got %q, %v", content, err)
+	}
+	if _, err := c.GetWeb(srv.URL+"/missing", WithStaleIfError()); err == nil {
+		t.Error("Expected an error for content that was never cached")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+
+	c, err = New(dir, WithOffline(true))
+	if err != nil {
+		t.Fatalf("Failed to reopen cache: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("cache.Close: %v", err)
+		}
+	})
+
+	requests := requestCount.Load()
+	content, err = c.GetWeb(srv.URL, WithForceRefresh())
+	if err != nil || string(content) != "list" {
+		t.Errorf("Expected the cached copy offline, got %q, %v", content, err)
+	}
+	if _, err := c.GetWeb(srv.URL + "/missing"); !errors.Is(err, ErrOffline) {
+		t.Errorf("Expected ErrOffline, got %v", err)
+	}
+	if n := requestCount.Load(); n != requests {
+		t.Errorf("Expected no requests in offline mode, got %d", n-requests)
+	}
+}
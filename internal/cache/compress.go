@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// Compression names the codec applied to an entry file.
+type Compression string
+
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+)
+
+// ParseCompression validates a compression name. "none" and the empty
+// string both disable compression.
+func ParseCompression(s string) (Compression, error) {
+	switch s {
+	case "", "none":
+		return CompressionNone, nil
+	case string(CompressionGzip):
+		return CompressionGzip, nil
+	}
+	return "", fmt.Errorf("unknown compression %q", s)
+}
+
+// compressWriter wraps w with the encoder for compression. The returned
+// closer flushes the encoder and must be called before w is closed.
+func compressWriter(w io.Writer, compression Compression) (io.Writer, io.Closer, error) {
+	switch compression {
+	case CompressionNone:
+		return w, io.NopCloser(nil), nil
+	case CompressionGzip:
+		zw := gzip.NewWriter(w)
+		return zw, zw, nil
+	}
+	return nil, nil, fmt.Errorf("unknown compression %q", compression)
+}
+
+// decompressReader wraps r with the decoder for compression.
+func decompressReader(r io.Reader, compression Compression) (io.Reader, error) {
+	switch compression {
+	case CompressionNone:
+		return r, nil
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	}
+	return nil, fmt.Errorf("unknown compression %q", compression)
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dirStorage keeps every name as a file in the cache directory.
+type dirStorage struct {
+	dir string
+}
+
+func newDirStorage(dir string) (*dirStorage, error) {
+	if _, err := os.ReadDir(dir); err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	return &dirStorage{dir: dir}, nil
+}
+
+func (s *dirStorage) Read(name string) ([]byte, error) {
+	filePath := filepath.Join(s.dir, name)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	return data, nil
+}
+
+func (s *dirStorage) Write(name string, parts ...[]byte) error {
+	return writeFile(filepath.Join(s.dir, name), parts...)
+}
+
+func (s *dirStorage) Remove(name string) error {
+	filePath := filepath.Join(s.dir, name)
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove cache file %s: %w", filePath, err)
+	}
+
+	return nil
+}
+
+func (s *dirStorage) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".bin") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (s *dirStorage) Quarantine(name string) error {
+	if err := os.MkdirAll(filepath.Join(s.dir, quarantineDir), 0755); err != nil {
+		return fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+
+	if err := os.Rename(filepath.Join(s.dir, name), filepath.Join(s.dir, quarantineDir, name)); err != nil {
+		return fmt.Errorf("failed to quarantine %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (s *dirStorage) Close() error {
+	return nil
+}
+
+// removeTempFiles deletes the temp files left in the cache directory by
+// writes that were interrupted. Writes to the directory happen under the
+// cross-process lock, so the caller must hold it: otherwise the temp file
+// of a write in progress in another process could be removed.
+func (c *Cache) removeTempFiles() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".tmp") {
+			if err := os.Remove(filepath.Join(c.dir, entry.Name())); err != nil {
+				log.Printf("warning: failed to remove stale tmp file %s: %v", entry.Name(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeFile atomically replaces filePath with the concatenation of parts.
+// The temp file has a unique name, so concurrent writers of the same file
+// never share one.
+func writeFile(filePath string, parts ...[]byte) error {
+	file, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for %s: %w", filePath, err)
+	}
+	tmpPath := file.Name()
+
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to create temp file %s: %w", tmpPath, err)
+	}
+
+	for _, part := range parts {
+		if _, err := file.Write(part); err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to write temp file %s: %w", tmpPath, err)
+		}
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync temp file %s: %w", tmpPath, err)
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close temp file %s: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", filePath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Entry files start with entryMagic, followed by the big-endian length of a
+// gob-encoded entryHeader, the header itself and the encoded payload. The
+// header makes every file self-describing, so the root index can be rebuilt
+// from the directory alone. Files written before the header was introduced
+// hold the bare payload.
+var entryMagic = []byte("FPLCACHE")
+
+type entryHeader struct {
+	Key      string
+	Metadata Metadata
+}
+
+var errNoHeader = errors.New("entry file has no header")
+
+func checksum(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// saveEntry writes data as the file of key and returns metadata completed
+// with the payload checksum and the file size.
+func (c *Cache) saveEntry(key string, metadata Metadata, data interface{}) (Metadata, error) {
+	payload, err := encode(data, metadata.Compression)
+	if err != nil {
+		return metadata, fmt.Errorf("failed to encode data of %s: %w", key, err)
+	}
+
+	return c.writeEntry(key, metadata, payload)
+}
+
+// writeEntry writes an already encoded payload as the file of key.
+func (c *Cache) writeEntry(key string, metadata Metadata, payload []byte) (Metadata, error) {
+	name := c.entryName(key)
+	metadata.Checksum = checksum(payload)
+
+	var header bytes.Buffer
+	if err := gob.NewEncoder(&header).Encode(entryHeader{Key: key, Metadata: metadata}); err != nil {
+		return metadata, fmt.Errorf("failed to encode header of %s: %w", name, err)
+	}
+	length := binary.BigEndian.AppendUint32(nil, uint32(header.Len()))
+
+	if err := c.storage.Write(name, entryMagic, length, header.Bytes(), payload); err != nil {
+		return metadata, err
+	}
+	metadata.Size = int64(len(entryMagic) + len(length) + header.Len() + len(payload))
+
+	return metadata, nil
+}
+
+// splitEntry separates the header of an entry file from its payload. Files
+// without a header return errNoHeader and the whole content as payload.
+func splitEntry(data []byte) (entryHeader, []byte, error) {
+	var header entryHeader
+	if !bytes.HasPrefix(data, entryMagic) {
+		return header, data, errNoHeader
+	}
+
+	rest := data[len(entryMagic):]
+	if len(rest) < 4 {
+		return header, nil, fmt.Errorf("%w: truncated header", ErrCorrupted)
+	}
+	length := binary.BigEndian.Uint32(rest)
+	rest = rest[4:]
+	if uint64(length) > uint64(len(rest)) {
+		return header, nil, fmt.Errorf("%w: truncated header", ErrCorrupted)
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(rest[:length])).Decode(&header); err != nil {
+		return header, nil, fmt.Errorf("%w: invalid header: %v", ErrCorrupted, err)
+	}
+
+	payload := rest[length:]
+	if header.Metadata.Checksum != "" && checksum(payload) != header.Metadata.Checksum {
+		return header, nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupted)
+	}
+
+	return header, payload, nil
+}
+
+// readEntry reads the payload of key and checks it against the checksum
+// recorded in metadata. Entries written before checksums were introduced
+// are returned unverified. It also returns the metadata describing the
+// file: when another process rewrote the entry after this cache read the
+// index, the file holds a newer, intact version whose header is used
+// instead of reporting corruption.
+func (c *Cache) readEntry(key string, metadata Metadata) ([]byte, Metadata, error) {
+	data, err := c.storage.Read(c.entryName(key))
+	if err != nil {
+		return nil, metadata, err
+	}
+
+	header, payload, err := splitEntry(data)
+	if err != nil && !errors.Is(err, errNoHeader) {
+		return nil, metadata, fmt.Errorf("%s: %w", key, err)
+	}
+
+	if err == nil && header.Key == key && header.Metadata.Checksum != metadata.Checksum &&
+		header.Metadata.UpdatedAt.After(metadata.UpdatedAt) {
+		header.Metadata.Size = int64(len(data))
+		return payload, mergeMetadata(metadata, header.Metadata), nil
+	}
+
+	if metadata.Checksum != "" && checksum(payload) != metadata.Checksum {
+		return nil, metadata, fmt.Errorf("%w: %s: checksum mismatch", ErrCorrupted, key)
+	}
+
+	return payload, metadata, nil
+}
+
+// loadEntry decodes the file of key into target. It returns ErrNotFound
+// when the file is missing. A corrupted file is quarantined and its entry
+// dropped. A file rewritten by another process updates the entry. The
+// caller must hold the write lock.
+func (c *Cache) loadEntry(key string, metadata Metadata, target interface{}) error {
+	payload, current, err := c.readEntry(key, metadata)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		if errors.Is(err, ErrCorrupted) {
+			c.quarantineEntry(key)
+		}
+		return err
+	}
+
+	if !current.UpdatedAt.Equal(metadata.UpdatedAt) {
+		c.rootIndex.Entries[key] = current
+		c.base[key] = current.UpdatedAt
+	}
+
+	if err := decode(payload, target, current.Compression); err != nil {
+		return fmt.Errorf("failed to decode data of %s: %w", key, err)
+	}
+
+	return nil
+}
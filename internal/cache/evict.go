@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EntryTypes lists every entry type stored by the cache.
+var EntryTypes = []EntryType{TypeScalar, TypeList, TypeWeb, TypeScanResult}
+
+// ParseEntryType validates the name of an entry type.
+func ParseEntryType(s string) (EntryType, error) {
+	for _, t := range EntryTypes {
+		if string(t) == s {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown entry type %q", s)
+}
+
+// Delete removes a single entry. It returns ErrNotFound when the key does
+// not exist.
+func (c *Cache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.rootIndex.Entries[key]; !exists {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	return c.withIndexLock(func() error {
+		if err := c.removeEntry(key); err != nil {
+			return err
+		}
+		return c.syncRootIndex()
+	})
+}
+
+// DeleteFunc removes every entry for which match returns true and reports
+// how many were removed.
+func (c *Cache) DeleteFunc(match func(key string, metadata Metadata) bool) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	err := c.withIndexLock(func() error {
+		for key, metadata := range c.rootIndex.Entries {
+			if !match(key, metadata) {
+				continue
+			}
+			if err := c.removeEntry(key); err != nil {
+				if saveErr := c.syncRootIndex(); saveErr != nil {
+					return fmt.Errorf("%w (and failed to save root index: %v)", err, saveErr)
+				}
+				return err
+			}
+			removed++
+		}
+
+		if removed == 0 {
+			return nil
+		}
+		return c.syncRootIndex()
+	})
+
+	return removed, err
+}
+
+// Filter selects entries by key prefix, type and age. Zero fields match
+// every entry.
+type Filter struct {
+	Prefix string
+	Type   EntryType
+	// OlderThan matches entries not updated within this duration.
+	OlderThan time.Duration
+}
+
+// Purge removes the entries matching every field of f and reports how many
+// were removed.
+func (c *Cache) Purge(f Filter) (int, error) {
+	var cutoff time.Time
+	if f.OlderThan > 0 {
+		cutoff = time.Now().Add(-f.OlderThan)
+	}
+
+	return c.DeleteFunc(func(key string, metadata Metadata) bool {
+		return strings.HasPrefix(key, f.Prefix) &&
+			(f.Type == "" || metadata.Type == f.Type) &&
+			(cutoff.IsZero() || metadata.UpdatedAt.Before(cutoff))
+	})
+}
+
+// removeEntry deletes the file of key and drops it from the in-memory
+// index. The caller must hold the write lock and save the index.
+func (c *Cache) removeEntry(key string) error {
+	if err := c.storage.Remove(c.entryName(key)); err != nil {
+		return err
+	}
+
+	c.forget(key)
+	return nil
+}
@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	rootIndexFile = "root.index.bin"
+	lockFileName  = "root.index.lock"
+)
+
+func (c *Cache) getLockPath() string {
+	return filepath.Join(c.dir, lockFileName)
+}
+
+// withIndexLock runs fn while holding an exclusive advisory lock on the
+// cache directory, serialising index updates across processes.
+func (c *Cache) withIndexLock(fn func() error) error {
+	lockPath := c.getLockPath()
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file %s: %w", lockPath, err)
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", lockPath, err)
+	}
+	defer unlockFile(f)
+
+	return fn()
+}
+
+func (c *Cache) loadRootIndex() error {
+	return c.withIndexLock(func() error {
+		index, err := c.readRootIndex()
+		if err != nil {
+			return err
+		}
+
+		c.rootIndex = index
+		c.rememberBase()
+		return nil
+	})
+}
+
+func (c *Cache) readRootIndex() (*RootIndex, error) {
+	index := &RootIndex{
+		Entries: make(map[string]Metadata),
+	}
+
+	err := c.loadFromFile(rootIndexFile, index, CompressionNone)
+	if errors.Is(err, ErrCorrupted) {
+		log.Printf("warning: %v; moving it to %s, run `cache verify -rebuild` to restore its entries", err, quarantineDir)
+		if err := c.storage.Quarantine(rootIndexFile); err != nil {
+			return nil, err
+		}
+		index.Entries = make(map[string]Metadata)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// A cache without entries has nothing to migrate.
+	if index.Version == 0 && len(index.Entries) == 0 {
+		index.Version = FormatVersion
+	}
+
+	return index, nil
+}
+
+// saveRootIndex merges the on-disk index into memory and writes the result
+// back, under the cross-process lock. This keeps entries added or removed
+// by other processes since this cache last read the index.
+func (c *Cache) saveRootIndex() error {
+	return c.withIndexLock(c.syncRootIndex)
+}
+
+// syncRootIndex is saveRootIndex for callers that already hold the
+// cross-process lock, so that changing entry files and saving the index
+// happen as one step for other processes.
+func (c *Cache) syncRootIndex() error {
+	disk, err := c.readRootIndex()
+	if err != nil {
+		return err
+	}
+
+	c.mergeRootIndex(disk)
+
+	if err := c.saveToFile(rootIndexFile, c.rootIndex, CompressionNone); err != nil {
+		return err
+	}
+
+	c.rememberBase()
+	return nil
+}
+
+// Reload merges the on-disk index into memory, picking up entries written
+// or removed by other processes since this cache last read it. Long-running
+// processes call it before serving reads.
+func (c *Cache) Reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.withIndexLock(func() error {
+		disk, err := c.readRootIndex()
+		if err != nil {
+			return err
+		}
+
+		c.mergeRootIndex(disk)
+		return nil
+	})
+}
+
+// mergeRootIndex folds the entries of the on-disk index into memory. For a
+// key known to both, the most recently updated entry wins, keeping the
+// latest pin and access of either side (see mergeMetadata). A key missing
+// from disk that this process has not touched since the last sync was
+// removed by another process and is dropped; a key this process removed is
+// only restored if another process wrote it again afterwards. The base is
+// moved to the disk state, so a merge without a save is also safe.
+func (c *Cache) mergeRootIndex(disk *RootIndex) {
+	for key, theirs := range disk.Entries {
+		c.base[key] = theirs.UpdatedAt
+		if removedAt, ok := c.removed[key]; ok && !theirs.UpdatedAt.After(removedAt) {
+			continue
+		}
+
+		if ours, ok := c.rootIndex.Entries[key]; ok {
+			c.rootIndex.Entries[key] = mergeMetadata(ours, theirs)
+		} else {
+			c.rootIndex.Entries[key] = theirs
+		}
+	}
+
+	for key, ours := range c.rootIndex.Entries {
+		if _, ok := disk.Entries[key]; ok {
+			continue
+		}
+		if baseUpdatedAt, ok := c.base[key]; ok && baseUpdatedAt.Equal(ours.UpdatedAt) {
+			delete(c.rootIndex.Entries, key)
+			delete(c.base, key)
+		}
+	}
+}
+
+// mergeMetadata combines two versions of the metadata of an entry. Pinning
+// and reading do not change UpdatedAt, so the pin is taken from the side
+// that changed it last and the access time is the latest of both.
+func mergeMetadata(ours, theirs Metadata) Metadata {
+	merged, other := ours, theirs
+	if theirs.UpdatedAt.After(ours.UpdatedAt) {
+		merged, other = theirs, ours
+	}
+
+	if other.PinnedAt.After(merged.PinnedAt) {
+		merged.Pinned, merged.PinnedAt = other.Pinned, other.PinnedAt
+	}
+	if other.AccessedAt.After(merged.AccessedAt) {
+		merged.AccessedAt = other.AccessedAt
+	}
+
+	return merged
+}
+
+// rememberBase records the index as last synchronised with disk.
+func (c *Cache) rememberBase() {
+	c.base = make(map[string]time.Time, len(c.rootIndex.Entries))
+	for key, metadata := range c.rootIndex.Entries {
+		c.base[key] = metadata.UpdatedAt
+	}
+	c.removed = make(map[string]time.Time)
+}
+
+// forget drops key from the in-memory index and records the removal so a
+// later merge does not bring it back.
+func (c *Cache) forget(key string) {
+	delete(c.rootIndex.Entries, key)
+	c.removed[key] = time.Now()
+}
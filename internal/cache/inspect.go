@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// EntryInfo describes a cache entry and its file on disk. SHA256 is the hash
+// of the whole file and is only computed by Inspect.
+type EntryInfo struct {
+	Key      string
+	Metadata Metadata
+	File     string
+	Size     int64
+	SHA256   string
+}
+
+// Inspect returns information about the entry stored under key, including
+// the size and SHA-256 of its file.
+func (c *Cache) Inspect(key string) (EntryInfo, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	metadata, exists := c.rootIndex.Entries[key]
+	if !exists {
+		return EntryInfo{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	return c.inspect(key, metadata)
+}
+
+// Entries returns information about every entry, sorted by key. It only
+// uses the index, so the size is the one recorded when the entry was
+// written and no file is read.
+func (c *Cache) Entries() []EntryInfo {
+	keys := c.Keys("", "")
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	infos := make([]EntryInfo, 0, len(keys))
+	for _, key := range keys {
+		metadata, exists := c.rootIndex.Entries[key]
+		if !exists {
+			continue
+		}
+		infos = append(infos, EntryInfo{
+			Key:      key,
+			Metadata: metadata,
+			File:     c.entryName(key),
+			Size:     metadata.Size,
+		})
+	}
+
+	return infos
+}
+
+// DirectoryReport returns what the scan of the cache directory found when
+// the cache was opened.
+func (c *Cache) DirectoryReport() DirectoryReport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.directoryReport
+}
+
+func (c *Cache) inspect(key string, metadata Metadata) (EntryInfo, error) {
+	name := c.entryName(key)
+	info := EntryInfo{
+		Key:      key,
+		Metadata: metadata,
+		File:     name,
+	}
+
+	data, err := c.storage.Read(name)
+	if err != nil {
+		return info, err
+	}
+
+	sum := sha256.Sum256(data)
+	info.Size = int64(len(data))
+	info.SHA256 = hex.EncodeToString(sum[:])
+	return info, nil
+}
@@ -0,0 +1,117 @@
+//go:build unix
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheOpenKeepsTempFilesOfOtherWriters(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	a, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache a: %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+
+	stale := filepath.Join(tmpDir, "stale.bin.1.tmp")
+	if err := os.WriteFile(stale, []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write stale temp file: %v", err)
+	}
+
+	// While a is in the middle of a write, holding the lock with its temp
+	// file on disk, another process opens the cache.
+	inFlight := filepath.Join(tmpDir, a.entryName("key")+".2.tmp")
+	opened := make(chan error, 1)
+	err = a.withIndexLock(func() error {
+		if err := os.WriteFile(inFlight, []byte("partial"), 0644); err != nil {
+			return err
+		}
+
+		go func() {
+			b, err := New(tmpDir)
+			if err == nil {
+				err = b.Close()
+			}
+			opened <- err
+		}()
+
+		select {
+		case err := <-opened:
+			t.Errorf("Expected opening to wait for the lock, got %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if _, err := os.Stat(inFlight); err != nil {
+			t.Errorf("Expected the temp file of the write in progress to be kept: %v", err)
+		}
+
+		return os.Remove(inFlight)
+	})
+	if err != nil {
+		t.Fatalf("withIndexLock: %v", err)
+	}
+
+	if err := <-opened; err != nil {
+		t.Fatalf("Failed to open cache b: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("Expected the stale temp file to be removed, got %v", err)
+	}
+}
+
+func TestCacheConcurrentMigration(t *testing.T) {
+	dir := copyFixture(t, "v0")
+
+	var runs atomic.Int32
+	original := migrations
+	migrations = []migration{{
+		From:        0,
+		Description: original[0].Description,
+		Migrate: func(c *Cache) error {
+			runs.Add(1)
+			// Give the other process time to load the old index.
+			time.Sleep(50 * time.Millisecond)
+			return original[0].Migrate(c)
+		},
+	}}
+	t.Cleanup(func() { migrations = original })
+
+	var wg sync.WaitGroup
+	caches := make([]*Cache, 2)
+	errs := make([]error, 2)
+	for i := range caches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			caches[i], errs[i] = New(dir)
+		}()
+	}
+	wg.Wait()
+
+	for i, c := range caches {
+		if errs[i] != nil {
+			t.Fatalf("Failed to open cache %d: %v", i, errs[i])
+		}
+		t.Cleanup(func() { c.Close() })
+		if c.rootIndex.Version != FormatVersion {
+			t.Errorf("Cache %d: expected version %d, got %d", i, FormatVersion, c.rootIndex.Version)
+		}
+	}
+	if n := runs.Load(); n != 1 {
+		t.Errorf("Expected the migration to run once, ran %d times", n)
+	}
+
+	report, err := caches[1].Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if report.Verified != 3 || len(report.Corrupted) != 0 {
+		t.Errorf("Expected every entry to verify: %+v", report)
+	}
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Pin protects an entry from LRU eviction, or removes that protection.
+func (c *Cache) Pin(key string, pinned bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	metadata, exists := c.rootIndex.Entries[key]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	metadata.Pinned = pinned
+	metadata.PinnedAt = time.Now()
+	c.rootIndex.Entries[key] = metadata
+
+	return c.saveRootIndex()
+}
+
+// touch records an access to key. The access time is persisted with the
+// next index save. The caller must hold the write lock.
+func (c *Cache) touch(key string) {
+	if metadata, exists := c.rootIndex.Entries[key]; exists {
+		metadata.AccessedAt = time.Now()
+		c.rootIndex.Entries[key] = metadata
+	}
+}
+
+// evict removes least recently used entries until the cache is within its
+// limits. Pinned entries and keep are never evicted. The caller must hold
+// the write lock and save the index.
+func (c *Cache) evict(keep string) error {
+	if c.maxBytes <= 0 && c.maxEntries <= 0 {
+		return nil
+	}
+
+	var total int64
+	for _, metadata := range c.rootIndex.Entries {
+		total += metadata.Size
+	}
+
+	for (c.maxEntries > 0 && len(c.rootIndex.Entries) > c.maxEntries) || (c.maxBytes > 0 && total > c.maxBytes) {
+		victim, found := "", false
+		var oldest time.Time
+		for key, metadata := range c.rootIndex.Entries {
+			if key == keep || metadata.Pinned {
+				continue
+			}
+			if !found || metadata.lastUsed().Before(oldest) {
+				victim, oldest, found = key, metadata.lastUsed(), true
+			}
+		}
+		if !found {
+			log.Printf("warning: cache exceeds its limits but every other entry is pinned")
+			return nil
+		}
+
+		size := c.rootIndex.Entries[victim].Size
+		if err := c.removeEntry(victim); err != nil {
+			return err
+		}
+		total -= size
+	}
+
+	return nil
+}
+
+// lastUsed is the access time used for LRU ordering. Entries that were
+// never read fall back to their update time.
+func (m Metadata) lastUsed() time.Time {
+	if m.AccessedAt.After(m.UpdatedAt) {
+		return m.AccessedAt
+	}
+	return m.UpdatedAt
+}
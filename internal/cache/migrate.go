@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+// FormatVersion is the layout version written to the root index. Caches
+// with an older version are upgraded by the migrations on New.
+const FormatVersion = 1
+
+// migration upgrades the cache from version From to From+1.
+type migration struct {
+	From        int
+	Description string
+	Migrate     func(c *Cache) error
+}
+
+// migrations is the registry of format upgrades, one per version, in order.
+// Version 0 is the original layout: an index without version and bare gob
+// entry files.
+var migrations = []migration{
+	{From: 0, Description: "add headers and checksums to entry files", Migrate: migrateEntryHeaders},
+}
+
+// migrate runs every migration needed to bring the cache to FormatVersion
+// and records the new version in the root index. The migrations run under
+// the cross-process lock, on the index as re-read after taking it, so that
+// two processes opening an old cache do not both rewrite it. The caller
+// must hold the write lock.
+func (c *Cache) migrate() error {
+	if err := checkVersion(c.rootIndex.Version); err != nil || c.rootIndex.Version == FormatVersion {
+		return err
+	}
+
+	return c.withIndexLock(func() error {
+		index, err := c.readRootIndex()
+		if err != nil {
+			return err
+		}
+		c.rootIndex = index
+		c.rememberBase()
+
+		// Another process may have migrated the cache in the meantime.
+		version := c.rootIndex.Version
+		if err := checkVersion(version); err != nil || version == FormatVersion {
+			return err
+		}
+
+		for _, m := range migrations {
+			if m.From < version {
+				continue
+			}
+			log.Printf("Migrating cache from version %d to %d: %s", m.From, m.From+1, m.Description)
+			if err := m.Migrate(c); err != nil {
+				return fmt.Errorf("failed to migrate cache from version %d: %w", m.From, err)
+			}
+			c.rootIndex.Version = m.From + 1
+		}
+
+		return c.syncRootIndex()
+	})
+}
+
+// checkVersion rejects caches written by a newer version of the program.
+func checkVersion(version int) error {
+	if version > FormatVersion {
+		return fmt.Errorf("cache format version %d is newer than supported version %d", version, FormatVersion)
+	}
+	return nil
+}
+
+// migrateEntryHeaders rewrites bare entry files with a header and records
+// their checksum, so they can be verified and recovered like new entries.
+func migrateEntryHeaders(c *Cache) error {
+	keys := make([]string, 0, len(c.rootIndex.Entries))
+	for key := range c.rootIndex.Entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		data, err := c.storage.Read(c.entryName(key))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if bytes.HasPrefix(data, entryMagic) {
+			continue
+		}
+
+		metadata, err := c.writeEntry(key, c.rootIndex.Entries[key], data)
+		if err != nil {
+			return err
+		}
+		c.rootIndex.Entries[key] = metadata
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package cache
+
+// Option configures a Cache created by New.
+type Option func(*Cache)
+
+// WithMaxBytes limits the total size of entry files. Least recently used
+// entries are evicted once the limit is exceeded. Zero means no limit.
+func WithMaxBytes(n int64) Option {
+	return func(c *Cache) {
+		c.maxBytes = n
+	}
+}
+
+// WithMaxEntries limits the number of entries. Least recently used entries
+// are evicted once the limit is exceeded. Zero means no limit.
+func WithMaxEntries(n int) Option {
+	return func(c *Cache) {
+		c.maxEntries = n
+	}
+}
+
+// WithCompression sets the codec used for newly written entries. Existing
+// entries keep the codec recorded in their metadata.
+func WithCompression(compression Compression) Option {
+	return func(c *Cache) {
+		c.compression = compression
+	}
+}
+
+// WithBackend selects the storage of the cache. Entries are not carried
+// over when the backend of an existing cache directory changes.
+func WithBackend(backend Backend) Option {
+	return func(c *Cache) {
+		c.backend = backend
+	}
+}
+
+// WithOffline makes GetWeb serve cached content only, however old, and fail
+// with ErrOffline for anything that is not cached.
+func WithOffline(offline bool) Option {
+	return func(c *Cache) {
+		c.offline = offline
+	}
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"fmt"
+)
+
+// Storage holds the files of a cache: one file per entry, named after the
+// hash of its key, and the root index. Names are flat and end in ".bin".
+type Storage interface {
+	// Read returns the content of name, or an error wrapping
+	// os.ErrNotExist when it is not stored.
+	Read(name string) ([]byte, error)
+	// Write atomically replaces name with the concatenation of parts.
+	Write(name string, parts ...[]byte) error
+	// Remove deletes name. Removing a missing name is not an error.
+	Remove(name string) error
+	// List returns the stored names, sorted.
+	List() ([]string, error)
+	// Quarantine moves name out of the store into the quarantine
+	// directory, where it is kept for inspection.
+	Quarantine(name string) error
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// Backend names a Storage implementation.
+type Backend string
+
+const (
+	// BackendDirectory stores every entry as a file in the cache directory.
+	BackendDirectory Backend = "directory"
+	// BackendFile stores all entries in a single bbolt database file.
+	BackendFile Backend = "file"
+)
+
+// ParseBackend validates a backend name. The empty string selects the
+// directory backend.
+func ParseBackend(s string) (Backend, error) {
+	switch Backend(s) {
+	case "", BackendDirectory:
+		return BackendDirectory, nil
+	case BackendFile:
+		return BackendFile, nil
+	}
+	return "", fmt.Errorf("unknown cache backend %q", s)
+}
+
+func openStorage(dir string, backend Backend) (Storage, error) {
+	switch backend {
+	case "", BackendDirectory:
+		return newDirStorage(dir)
+	case BackendFile:
+		return newBoltStorage(dir)
+	}
+	return nil, fmt.Errorf("unknown cache backend %q", backend)
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SetTyped stores value under key as an entry of type t. Unlike Set, the
+// value is encoded as its concrete type, so it must be read back with
+// GetTyped using the same type parameter.
+func SetTyped[T any](c *Cache, key string, t EntryType, value T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.put(key, Metadata{Type: t}, value)
+}
+
+// GetTyped loads the entry stored under key by SetTyped. It returns
+// ErrNotFound when the key does not exist and an error when the entry is
+// not of type t.
+func GetTyped[T any](c *Cache, key string, t EntryType) (T, error) {
+	var value T
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	metadata, exists := c.rootIndex.Entries[key]
+	if !exists {
+		return value, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	if metadata.Type != t {
+		return value, fmt.Errorf("key %s is not a %s entry", key, t)
+	}
+
+	if err := c.loadEntry(key, metadata, &value); err != nil {
+		return value, err
+	}
+	c.touch(key)
+
+	return value, nil
+}
+
+// Keys returns the sorted keys that start with prefix. When t is not empty
+// only entries of that type are returned.
+func (c *Cache) Keys(prefix string, t EntryType) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var keys []string
+	for key, metadata := range c.rootIndex.Entries {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if t != "" && metadata.Type != t {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Metadata returns the index entry for key.
+func (c *Cache) Metadata(key string) (Metadata, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	metadata, ok := c.rootIndex.Entries[key]
+	return metadata, ok
+}
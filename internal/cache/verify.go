@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sort"
+)
+
+const quarantineDir = "quarantine"
+
+// VerifyReport is the outcome of Verify. Unverified entries were written
+// before checksums were introduced; Corrupted entries were quarantined and
+// Missing ones had no file. Both were dropped from the index.
+type VerifyReport struct {
+	Verified   int
+	Unverified []string
+	Corrupted  []string
+	Missing    []string
+}
+
+// RebuildReport is the outcome of RebuildIndex. Recovered lists the keys
+// restored from orphaned files. Unrecoverable lists files that could not be
+// restored: files written before entry headers were introduced, or files
+// whose name does not match their key. Corrupted orphans are quarantined.
+type RebuildReport struct {
+	Recovered     []string
+	Unrecoverable []string
+	Corrupted     []string
+}
+
+// quarantineEntry quarantines the file of key and drops it from the index.
+// The caller must hold the write lock.
+func (c *Cache) quarantineEntry(key string) {
+	name := c.entryName(key)
+	log.Printf("warning: cache entry %s is corrupted, moving %s to %s", key, name, quarantineDir)
+
+	if err := c.storage.Quarantine(name); err != nil {
+		log.Printf("warning: %v", err)
+	}
+	c.forget(key)
+
+	if err := c.saveRootIndex(); err != nil {
+		log.Printf("warning: failed to persist root index: %v", err)
+	}
+}
+
+// Verify checks every entry against its checksum. Corrupted files are
+// quarantined, and corrupted or missing entries are dropped from the index.
+func (c *Cache) Verify() (VerifyReport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.rootIndex.Entries))
+	for key := range c.rootIndex.Entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var report VerifyReport
+	for _, key := range keys {
+		_, metadata, err := c.readEntry(key, c.rootIndex.Entries[key])
+		switch {
+		case err == nil && metadata.Checksum == "":
+			report.Unverified = append(report.Unverified, key)
+		case err == nil:
+			report.Verified++
+		case errors.Is(err, os.ErrNotExist):
+			report.Missing = append(report.Missing, key)
+			c.forget(key)
+		case errors.Is(err, ErrCorrupted):
+			report.Corrupted = append(report.Corrupted, key)
+			log.Printf("warning: %v", err)
+			if err := c.storage.Quarantine(c.entryName(key)); err != nil {
+				return report, err
+			}
+			c.forget(key)
+		default:
+			return report, err
+		}
+	}
+
+	return report, c.saveRootIndex()
+}
+
+// RebuildIndex restores index entries from orphaned files, using the header
+// stored in each file. Run it after the root index was lost or quarantined.
+func (c *Cache) RebuildIndex() (RebuildReport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var report RebuildReport
+
+	names, err := c.storage.List()
+	if err != nil {
+		return report, err
+	}
+
+	known := make(map[string]bool, len(c.rootIndex.Entries))
+	for key := range c.rootIndex.Entries {
+		known[c.entryName(key)] = true
+	}
+
+	for _, name := range names {
+		if name == rootIndexFile || known[name] {
+			continue
+		}
+
+		data, err := c.storage.Read(name)
+		if err != nil {
+			return report, err
+		}
+
+		header, _, err := splitEntry(data)
+		switch {
+		case errors.Is(err, errNoHeader):
+			report.Unrecoverable = append(report.Unrecoverable, name)
+			continue
+		case err != nil:
+			log.Printf("warning: %s: %v", name, err)
+			report.Corrupted = append(report.Corrupted, name)
+			if err := c.storage.Quarantine(name); err != nil {
+				return report, err
+			}
+			continue
+		case c.entryName(header.Key) != name:
+			report.Unrecoverable = append(report.Unrecoverable, name)
+			continue
+		}
+
+		metadata := header.Metadata
+		metadata.Size = int64(len(data))
+		c.rootIndex.Entries[header.Key] = metadata
+		delete(c.removed, header.Key)
+		report.Recovered = append(report.Recovered, header.Key)
+	}
+	sort.Strings(report.Recovered)
+
+	c.directoryReport.Orphaned = report.Unrecoverable
+
+	return report, c.saveRootIndex()
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+var httpClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// ErrOffline is returned by GetWeb in offline mode when the requested
+// content is not cached.
+var ErrOffline = errors.New("offline mode")
+
+type webOptions struct {
+	maxAge       time.Duration
+	force        bool
+	staleIfError bool
+}
+
+// WebOption configures a GetWeb call.
+type WebOption func(*webOptions)
+
+// WithMaxAge sets how long the downloaded content stays fresh. The value is
+// stored with the entry and used by later calls that do not set it. A zero
+// max age keeps the content fresh forever.
+func WithMaxAge(d time.Duration) WebOption {
+	return func(o *webOptions) {
+		o.maxAge = d
+	}
+}
+
+// WithForceRefresh revalidates the cached content even if it is still fresh.
+func WithForceRefresh() WebOption {
+	return func(o *webOptions) {
+		o.force = true
+	}
+}
+
+// WithStaleIfError returns the cached content, with a warning, when it
+// cannot be revalidated because the download fails.
+func WithStaleIfError() WebOption {
+	return func(o *webOptions) {
+		o.staleIfError = true
+	}
+}
+
+// GetWeb returns the content of url, downloading it on a cache miss. Once
+// the cached copy is older than its max age, or when a refresh is forced,
+// it is revalidated with If-None-Match/If-Modified-Since; a 304 response
+// keeps the cached content and only bumps UpdatedAt. In offline mode the
+// network is never used and only cached content is returned.
+func (c *Cache) GetWeb(url string, opts ...WebOption) ([]byte, error) {
+	var o webOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	key := url
+
+	c.mu.Lock()
+	metadata, exists := c.rootIndex.Entries[key]
+	var cached []byte
+	if exists && metadata.Type == TypeWeb {
+		err := c.loadEntry(key, metadata, &cached)
+		switch {
+		case errors.Is(err, ErrCorrupted):
+			// The file was quarantined; download it again.
+			log.Printf("warning: %v", err)
+		case err != nil:
+			c.mu.Unlock()
+			return nil, err
+		case cached == nil:
+			c.mu.Unlock()
+			return nil, fmt.Errorf("cached data missing for key %s", key)
+		default:
+			metadata = c.rootIndex.Entries[key]
+			c.touch(key)
+		}
+	}
+	c.mu.Unlock()
+
+	maxAge := o.maxAge
+	if maxAge == 0 {
+		maxAge = metadata.MaxAge
+	}
+
+	fresh := maxAge == 0 || time.Since(metadata.UpdatedAt) < maxAge
+	if cached != nil && !o.force && fresh {
+		return cached, nil
+	}
+
+	if c.offline {
+		if cached == nil {
+			return nil, fmt.Errorf("%w: %s is not cached", ErrOffline, url)
+		}
+		if !fresh {
+			log.Printf("warning: offline, using cached copy of %s from %s", url, metadata.UpdatedAt.Format(time.RFC3339))
+		}
+		return cached, nil
+	}
+
+	content, err := c.download(key, url, cached, metadata, maxAge)
+	if err != nil && cached != nil && o.staleIfError {
+		log.Printf("warning: %v; using cached copy from %s", err, metadata.UpdatedAt.Format(time.RFC3339))
+		return cached, nil
+	}
+
+	return content, err
+}
+
+// download fetches url, revalidating the cached copy if there is one, and
+// stores the result under key.
+func (c *Cache) download(key, url string, cached []byte, metadata Metadata, maxAge time.Duration) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from %s: %w", url, err)
+	}
+	if cached != nil {
+		if metadata.ETag != "" {
+			req.Header.Set("If-None-Match", metadata.ETag)
+		}
+		if metadata.LastModified != "" {
+			req.Header.Set("If-Modified-Since", metadata.LastModified)
+		}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if current, ok := c.rootIndex.Entries[key]; ok && current.Type == TypeWeb {
+			current.UpdatedAt = time.Now()
+			current.MaxAge = maxAge
+			c.rootIndex.Entries[key] = current
+		}
+		return cached, c.saveRootIndex()
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download from %s: status code %d", url, resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err = c.put(key, Metadata{
+		Type:         TypeWeb,
+		MaxAge:       maxAge,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, content)
+	if err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
@@ -0,0 +1,374 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/network"
+)
+
+// maxJobs is the number of scan jobs kept for status polling. The oldest
+// finished jobs are dropped first.
+const maxJobs = 100
+
+func apiCommand() *Command {
+	var listen string
+	return &Command{
+		Name:    "api",
+		Summary: "Serve collections, statistics and scans as a JSON HTTP API",
+		Help: "Endpoints:\n" +
+			"  GET  /collections               - Configured collections\n" +
+			"  GET  /collections/{name}/stats  - Statistics of the latest scan\n" +
+			"  GET  /collections/{name}/fast   - Fastest working proxies, ?n= (default: 1)\n" +
+			"  POST /collections/{name}/scan   - Start a scan, ?force=true to revalidate the list\n" +
+			"  GET  /jobs                      - Recent scan jobs\n" +
+			"  GET  /jobs/{id}                 - Status of a scan job",
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&listen, "listen", "127.0.0.1:8080", "Address to listen on")
+		},
+		Run: func(env *Env, args []string) error {
+			return serveAPI(env, listen)
+		},
+	}
+}
+
+// serveAPI runs the API until interrupted, then cancels the running scans
+// and waits for them.
+func serveAPI(env *Env, listen string) error {
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	a := newAPI(ctx, env)
+	server := &http.Server{
+		Handler:           a.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.Serve(l)
+	}()
+	log.Printf("Serving the API on http://%s", l.Addr())
+
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = server.Shutdown(shutdownCtx)
+		cancel()
+	}
+
+	a.jobs.Wait()
+	log.Println("API stopped")
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("api server failed: %w", err)
+	}
+	return nil
+}
+
+// scanJob is the state of an asynchronous scan started through the API.
+type scanJob struct {
+	ID         string     `json:"id"`
+	Collection string     `json:"collection"`
+	Force      bool       `json:"force"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Proxies    int        `json:"proxies"`
+	Working    int        `json:"working"`
+}
+
+const (
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+)
+
+type apiServer struct {
+	ctx context.Context
+	env *Env
+	// scan runs a scan job; it is runScan except in tests.
+	scan func(ctx context.Context, env *Env, collection string, force bool) (*network.Report, error)
+
+	mu    sync.Mutex
+	order []string
+	byID  map[string]*scanJob
+	jobs  sync.WaitGroup
+}
+
+func newAPI(ctx context.Context, env *Env) *apiServer {
+	return &apiServer{
+		ctx:  ctx,
+		env:  env,
+		scan: runScan,
+		byID: map[string]*scanJob{},
+	}
+}
+
+func (a *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /collections", a.listCollections)
+	mux.HandleFunc("GET /collections/{name}/stats", a.collectionStats)
+	mux.HandleFunc("GET /collections/{name}/fast", a.fastProxies)
+	mux.HandleFunc("POST /collections/{name}/scan", a.startScan)
+	mux.HandleFunc("GET /jobs", a.listJobs)
+	mux.HandleFunc("GET /jobs/{id}", a.getJob)
+	return mux
+}
+
+func (a *apiServer) listCollections(w http.ResponseWriter, r *http.Request) {
+	cfg := a.env.Config
+	records := []collectionRecord{}
+	for _, name := range cfg.ProxyCollectionList.Names() {
+		records = append(records, newCollectionRecord(name, cfg.ProxyCollectionList[name]))
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+func (a *apiServer) collectionStats(w http.ResponseWriter, r *http.Request) {
+	name, ok := a.collection(w, r)
+	if !ok {
+		return
+	}
+
+	if !a.reload(w) {
+		return
+	}
+	report, metadata, err := network.LatestReport(a.env.Cache, name)
+	if err != nil {
+		writeReportError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newStatsRecord(name, report, metadata.UpdatedAt, network.Summarize(report)))
+}
+
+func (a *apiServer) fastProxies(w http.ResponseWriter, r *http.Request) {
+	name, ok := a.collection(w, r)
+	if !ok {
+		return
+	}
+
+	number := 1
+	if s := r.URL.Query().Get("n"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("n must be a positive integer, got %q", s))
+			return
+		}
+		number = n
+	}
+
+	if !a.reload(w) {
+		return
+	}
+	ranked, err := network.RankCollection(a.env.Cache, name, a.env.Config.Ranking)
+	if err != nil {
+		writeReportError(w, err)
+		return
+	}
+
+	ranked = ranked[:min(number, len(ranked))]
+	records := make([]rankedRecord, len(ranked))
+	for i, r := range ranked {
+		records[i] = newRankedRecord(i+1, r)
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+func (a *apiServer) startScan(w http.ResponseWriter, r *http.Request) {
+	name, ok := a.collection(w, r)
+	if !ok {
+		return
+	}
+	if err := checkScannable(a.env, name); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+
+	force := false
+	if s := r.URL.Query().Get("force"); s != "" {
+		var err error
+		if force, err = strconv.ParseBool(s); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("force must be a boolean, got %q", s))
+			return
+		}
+	}
+
+	job, started := a.startJob(name, force)
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	if !started {
+		writeJSON(w, http.StatusConflict, job)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// startJob starts a scan of collection in the background and returns a
+// snapshot of its job. If the collection is already being scanned, the
+// running job is returned instead and started is false.
+func (a *apiServer) startJob(collection string, force bool) (job scanJob, started bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, id := range a.order {
+		if j := a.byID[id]; j.Collection == collection && j.Status == jobRunning {
+			return *j, false
+		}
+	}
+
+	j := &scanJob{
+		ID:         newJobID(),
+		Collection: collection,
+		Force:      force,
+		Status:     jobRunning,
+		StartedAt:  time.Now(),
+	}
+	a.byID[j.ID] = j
+	a.order = append(a.order, j.ID)
+	a.pruneJobs()
+
+	a.jobs.Add(1)
+	go a.runJob(j)
+
+	return *j, true
+}
+
+func (a *apiServer) runJob(j *scanJob) {
+	defer a.jobs.Done()
+
+	log.Printf("Scan job %s started for collection %s", j.ID, j.Collection)
+	report, err := a.scan(a.ctx, a.env, j.Collection, j.Force)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	j.FinishedAt = &now
+	if err != nil {
+		j.Status, j.Error = jobFailed, err.Error()
+		log.Printf("Scan job %s failed: %v", j.ID, err)
+		return
+	}
+
+	j.Status = jobSucceeded
+	j.Proxies = len(report.Results)
+	for _, result := range report.Results {
+		if result.OK {
+			j.Working++
+		}
+	}
+	log.Printf("Scan job %s finished: %d of %d proxies working", j.ID, j.Working, j.Proxies)
+}
+
+// pruneJobs drops the oldest finished jobs beyond maxJobs. The caller must
+// hold the lock.
+func (a *apiServer) pruneJobs() {
+	for i := 0; len(a.order) > maxJobs && i < len(a.order); {
+		if id := a.order[i]; a.byID[id].Status != jobRunning {
+			delete(a.byID, id)
+			a.order = append(a.order[:i], a.order[i+1:]...)
+			continue
+		}
+		i++
+	}
+}
+
+func (a *apiServer) listJobs(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	jobs := make([]scanJob, 0, len(a.order))
+	for i := len(a.order) - 1; i >= 0; i-- {
+		jobs = append(jobs, *a.byID[a.order[i]])
+	}
+	a.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+func (a *apiServer) getJob(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	j, ok := a.byID[r.PathValue("id")]
+	var job scanJob
+	if ok {
+		job = *j
+	}
+	a.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job %s not found", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// collection returns the collection named in the path, answering 404 if
+// it does not exist.
+func (a *apiServer) collection(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name := r.PathValue("name")
+	if _, err := lookupCollection(a.env.Config, name); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return "", false
+	}
+	return name, true
+}
+
+// reload picks up the scans saved by other processes since the cache was
+// opened, answering 500 if the index cannot be read.
+func (a *apiServer) reload(w http.ResponseWriter) bool {
+	if err := a.env.Cache.Reload(); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to reload the cache index: %w", err))
+		return false
+	}
+	return true
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("api: failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeReportError answers 404 for a collection without scan results and
+// 500 for anything else.
+func writeReportError(w http.ResponseWriter, err error) {
+	if errors.Is(err, network.ErrNoReport) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
@@ -0,0 +1,225 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/cache"
+	"free-proxy-list-speed-checker/internal/config"
+	"free-proxy-list-speed-checker/internal/network"
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+func newTestAPI(t *testing.T) (*apiServer, *httptest.Server) {
+	t.Helper()
+
+	c, err := cache.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	cfg := &config.Config{
+		ProxyCollectionList: config.ProxyCollectionList{
+			"socks5": {Url: "http://127.0.0.1:9/socks5.txt", Protocol: "socks5", Enabled: true},
+			"http":   {Url: "http://127.0.0.1:9/http.txt", Protocol: "http", Enabled: false},
+		},
+	}
+
+	a := newAPI(context.Background(), &Env{Config: cfg, Cache: c})
+	server := httptest.NewServer(a.handler())
+	t.Cleanup(server.Close)
+
+	return a, server
+}
+
+func testReport(collection string) *network.Report {
+	now := time.Now()
+	proxy := func(port int) proxylist.Proxy {
+		return proxylist.Proxy{Scheme: "socks5", Host: "127.0.0.1", Port: port}
+	}
+	return &network.Report{
+		Collection: collection,
+		StartedAt:  now.Add(-time.Second),
+		FinishedAt: now,
+		Results: []network.Result{
+			{Proxy: proxy(1080), Connected: true, Handshake: true, OK: true, Latency: 20 * time.Millisecond, CheckedAt: now},
+			{Proxy: proxy(1081), Connected: true, Handshake: true, OK: true, Latency: 10 * time.Millisecond, CheckedAt: now},
+			{Proxy: proxy(1082), FailedStage: network.StageDial, CheckedAt: now},
+		},
+	}
+}
+
+// request sends a request to the API and decodes the JSON response into
+// target.
+func request(t *testing.T, method, url string, wantStatus int, target any) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("%s %s: expected status %d, got %d", method, url, wantStatus, resp.StatusCode)
+	}
+	if target == nil {
+		return
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("%s %s: expected JSON, got %s", method, url, ct)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		t.Fatalf("%s %s: failed to decode response: %v", method, url, err)
+	}
+}
+
+func TestAPIReadEndpoints(t *testing.T) {
+	a, server := newTestAPI(t)
+
+	var collections []collectionRecord
+	request(t, "GET", server.URL+"/collections", http.StatusOK, &collections)
+	if len(collections) != 2 || collections[0].Name != "http" || collections[0].Enabled || collections[1].Name != "socks5" {
+		t.Errorf("Unexpected collections: %+v", collections)
+	}
+
+	request(t, "GET", server.URL+"/collections/socks5/stats", http.StatusNotFound, nil)
+	request(t, "GET", server.URL+"/collections/missing/stats", http.StatusNotFound, nil)
+
+	if err := network.SaveReport(a.env.Cache, testReport("socks5")); err != nil {
+		t.Fatalf("SaveReport failed: %v", err)
+	}
+
+	var stats statsRecord
+	request(t, "GET", server.URL+"/collections/socks5/stats", http.StatusOK, &stats)
+	if stats.Collection != "socks5" || stats.Parsed != 3 || stats.Working != 2 || stats.Failures.Dial != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	var fast []rankedRecord
+	request(t, "GET", server.URL+"/collections/socks5/fast?n=5", http.StatusOK, &fast)
+	if len(fast) != 2 || fast[0].Port != 1081 || fast[0].Rank != 1 || fast[1].Port != 1080 {
+		t.Errorf("Unexpected fastest proxies: %+v", fast)
+	}
+
+	request(t, "GET", server.URL+"/collections/socks5/fast", http.StatusOK, &fast)
+	if len(fast) != 1 {
+		t.Errorf("Expected one proxy by default, got %d", len(fast))
+	}
+
+	request(t, "GET", server.URL+"/collections/socks5/fast?n=zero", http.StatusBadRequest, nil)
+	request(t, "DELETE", server.URL+"/collections", http.StatusMethodNotAllowed, nil)
+}
+
+func TestAPIFastReturnsClientURLs(t *testing.T) {
+	a, server := newTestAPI(t)
+	a.env.Config.ProxyCollectionList["https"] = config.Collection{
+		Url: "http://127.0.0.1:9/https.txt", Protocol: "https", Enabled: true,
+	}
+
+	report := testReport("https")
+	for i := range report.Results {
+		report.Results[i].Proxy.Scheme = "https"
+	}
+	if err := network.SaveReport(a.env.Cache, report); err != nil {
+		t.Fatalf("SaveReport failed: %v", err)
+	}
+
+	// An https proxy is a plain HTTP proxy that supports CONNECT, so
+	// clients must not be told to reach it over TLS.
+	var fast []rankedRecord
+	request(t, "GET", server.URL+"/collections/https/fast", http.StatusOK, &fast)
+	if len(fast) != 1 || fast[0].Proxy != "http://127.0.0.1:1081" || fast[0].Scheme != "https" {
+		t.Errorf("Unexpected fastest proxies: %+v", fast)
+	}
+}
+
+func TestAPISeesScansOfOtherProcesses(t *testing.T) {
+	a, server := newTestAPI(t)
+
+	// A scan run by another process writes through its own Cache.
+	other, err := cache.New(a.env.Cache.Dir())
+	if err != nil {
+		t.Fatalf("Failed to open the cache again: %v", err)
+	}
+	t.Cleanup(func() { other.Close() })
+
+	request(t, "GET", server.URL+"/collections/socks5/stats", http.StatusNotFound, nil)
+
+	if err := network.SaveReport(other, testReport("socks5")); err != nil {
+		t.Fatalf("SaveReport failed: %v", err)
+	}
+
+	var stats statsRecord
+	request(t, "GET", server.URL+"/collections/socks5/stats", http.StatusOK, &stats)
+	if stats.Working != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	var fast []rankedRecord
+	request(t, "GET", server.URL+"/collections/socks5/fast", http.StatusOK, &fast)
+	if len(fast) != 1 || fast[0].Port != 1081 {
+		t.Errorf("Unexpected fastest proxies: %+v", fast)
+	}
+}
+
+func TestAPIScanJobs(t *testing.T) {
+	a, server := newTestAPI(t)
+
+	release := make(chan struct{})
+	a.scan = func(ctx context.Context, env *Env, collection string, force bool) (*network.Report, error) {
+		<-release
+		if force {
+			return nil, errors.New("download failed")
+		}
+		return testReport(collection), nil
+	}
+
+	var job scanJob
+	request(t, "POST", server.URL+"/collections/socks5/scan", http.StatusAccepted, &job)
+	if job.ID == "" || job.Status != jobRunning || job.Collection != "socks5" {
+		t.Fatalf("Unexpected job: %+v", job)
+	}
+
+	var running scanJob
+	request(t, "POST", server.URL+"/collections/socks5/scan", http.StatusConflict, &running)
+	if running.ID != job.ID {
+		t.Errorf("Expected the running job %s, got %s", job.ID, running.ID)
+	}
+
+	close(release)
+	a.jobs.Wait()
+
+	request(t, "GET", server.URL+"/jobs/"+job.ID, http.StatusOK, &job)
+	if job.Status != jobSucceeded || job.Proxies != 3 || job.Working != 2 || job.FinishedAt == nil {
+		t.Errorf("Unexpected finished job: %+v", job)
+	}
+
+	request(t, "POST", server.URL+"/collections/socks5/scan?force=true", http.StatusAccepted, &job)
+	a.jobs.Wait()
+	request(t, "GET", server.URL+"/jobs/"+job.ID, http.StatusOK, &job)
+	if job.Status != jobFailed || !strings.Contains(job.Error, "download failed") {
+		t.Errorf("Expected a failed job, got %+v", job)
+	}
+
+	var jobs []scanJob
+	request(t, "GET", server.URL+"/jobs", http.StatusOK, &jobs)
+	if len(jobs) != 2 || jobs[0].ID != job.ID {
+		t.Errorf("Expected 2 jobs, newest first, got %+v", jobs)
+	}
+
+	request(t, "GET", server.URL+"/jobs/unknown", http.StatusNotFound, nil)
+	request(t, "POST", server.URL+"/collections/http/scan", http.StatusConflict, nil)
+	request(t, "POST", server.URL+"/collections/missing/scan", http.StatusNotFound, nil)
+}
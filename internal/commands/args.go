@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"flag"
+)
+
+// parseArgs parses fs from args, allowing flags to appear before, between
+// or after positional arguments, and returns the positional arguments.
+// Everything after a "--" argument is positional.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		// Parse drops the "--" that ends the flags.
+		if consumed := len(args) - fs.NArg(); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, fs.Args()...), nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
@@ -0,0 +1,242 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/cache"
+)
+
+func cacheCommand() *Command {
+	var rebuild bool
+	return &Command{
+		Name:    "cache",
+		Summary: "Inspect the cache",
+		Subcommands: []*Command{
+			{
+				Name:    "ls",
+				Summary: "List entries with type, update time, size and checksum",
+				Run: func(env *Env, args []string) error {
+					return cacheLs(env.Cache)
+				},
+			},
+			{
+				Name:    "show",
+				Args:    "<key>",
+				Summary: "Show all details of one entry",
+				MinArgs: 1,
+				MaxArgs: 1,
+				Run: func(env *Env, args []string) error {
+					return cacheShow(env.Cache, args[0])
+				},
+			},
+			{
+				Name:    "stats",
+				Summary: "Summarise entries and report orphaned or dangling files",
+				Run: func(env *Env, args []string) error {
+					return cacheStats(env.Cache)
+				},
+			},
+			{
+				Name:    "verify",
+				Summary: "Check every entry against its checksum and quarantine corrupted files",
+				Flags: func(fs *flag.FlagSet) {
+					fs.BoolVar(&rebuild, "rebuild", false, "Also restore index entries from orphaned files")
+				},
+				Run: func(env *Env, args []string) error {
+					return cacheVerify(env.Cache, rebuild)
+				},
+			},
+			{
+				Name:    "pin",
+				Args:    "<key>",
+				Summary: "Protect an entry from size-based eviction",
+				MinArgs: 1,
+				MaxArgs: 1,
+				Run: func(env *Env, args []string) error {
+					return cachePin(env.Cache, args[0], true)
+				},
+			},
+			{
+				Name:    "unpin",
+				Args:    "<key>",
+				Summary: "Allow an entry to be evicted again",
+				MinArgs: 1,
+				MaxArgs: 1,
+				Run: func(env *Env, args []string) error {
+					return cachePin(env.Cache, args[0], false)
+				},
+			},
+		},
+	}
+}
+
+func cachePin(c *cache.Cache, key string, pinned bool) error {
+	if err := c.Pin(key, pinned); err != nil {
+		return err
+	}
+
+	if pinned {
+		fmt.Printf("Pinned %s\n", key)
+	} else {
+		fmt.Printf("Unpinned %s\n", key)
+	}
+	return nil
+}
+
+// cacheLs lists the entries from the index without reading their files;
+// cache verify checks them.
+func cacheLs(c *cache.Cache) error {
+	fmt.Printf("%-12s %-20s %10s  %-12s %-3s  %s\n", "TYPE", "UPDATED", "SIZE", "CHECKSUM", "PIN", "KEY")
+	for _, info := range c.Entries() {
+		pin := ""
+		if info.Metadata.Pinned {
+			pin = "yes"
+		}
+		sum := "-"
+		if info.Metadata.Checksum != "" {
+			sum = info.Metadata.Checksum[:12]
+		}
+		fmt.Printf("%-12s %-20s %10s  %-12s %-3s  %s\n",
+			info.Metadata.Type,
+			info.Metadata.UpdatedAt.Format(time.DateTime),
+			formatBytes(info.Size),
+			sum,
+			pin,
+			info.Key)
+	}
+
+	return nil
+}
+
+func cacheShow(c *cache.Cache, key string) error {
+	info, err := c.Inspect(key)
+	if err != nil {
+		return err
+	}
+
+	m := info.Metadata
+	fmt.Printf("Key:        %s\n", info.Key)
+	fmt.Printf("Type:       %s\n", m.Type)
+	fmt.Printf("Created:    %s\n", m.CreatedAt.Format(time.RFC3339))
+	fmt.Printf("Updated:    %s (%s ago)\n", m.UpdatedAt.Format(time.RFC3339), time.Since(m.UpdatedAt).Round(time.Second))
+	fmt.Printf("File:       %s\n", info.File)
+	fmt.Printf("Size:       %s (%d bytes)\n", formatBytes(info.Size), info.Size)
+	fmt.Printf("SHA256:     %s\n", info.SHA256)
+	if m.Checksum != "" {
+		fmt.Printf("Checksum:   %s\n", m.Checksum)
+	}
+	fmt.Printf("Accessed:   %s\n", m.AccessedAt.Format(time.RFC3339))
+	fmt.Printf("Pinned:     %t\n", m.Pinned)
+	compression := string(m.Compression)
+	if compression == "" {
+		compression = "none"
+	}
+	fmt.Printf("Compressed: %s\n", compression)
+	if m.Type == cache.TypeWeb {
+		fmt.Printf("Max age:    %s\n", m.MaxAge)
+		fmt.Printf("ETag:       %s\n", m.ETag)
+		fmt.Printf("Modified:   %s\n", m.LastModified)
+	}
+
+	return nil
+}
+
+func cacheStats(c *cache.Cache) error {
+	infos := c.Entries()
+
+	counts := map[cache.EntryType]int{}
+	sizes := map[cache.EntryType]int64{}
+	var total int64
+	for _, info := range infos {
+		counts[info.Metadata.Type]++
+		sizes[info.Metadata.Type] += info.Size
+		total += info.Size
+	}
+
+	fmt.Printf("Cache directory: %s\n", c.Dir())
+	fmt.Printf("Entries:         %d (%s)\n", len(infos), formatBytes(total))
+	for _, t := range cache.EntryTypes {
+		if counts[t] > 0 {
+			fmt.Printf("  %-12s %6d %10s\n", t, counts[t], formatBytes(sizes[t]))
+		}
+	}
+
+	report := c.DirectoryReport()
+	fmt.Printf("Orphaned files:  %d\n", len(report.Orphaned))
+	for _, name := range report.Orphaned {
+		fmt.Printf("  %s\n", name)
+	}
+	fmt.Printf("Dangling entries removed on open: %d\n", len(report.Dangling))
+	for _, key := range report.Dangling {
+		fmt.Printf("  %s\n", key)
+	}
+
+	return nil
+}
+
+func cacheVerify(c *cache.Cache, rebuild bool) error {
+	report, err := c.Verify()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Verified entries:   %d\n", report.Verified)
+	fmt.Printf("Without checksum:   %d\n", len(report.Unverified))
+	fmt.Printf("Corrupted entries:  %d\n", len(report.Corrupted))
+	for _, key := range report.Corrupted {
+		fmt.Printf("  %s\n", key)
+	}
+	fmt.Printf("Missing files:      %d\n", len(report.Missing))
+	for _, key := range report.Missing {
+		fmt.Printf("  %s\n", key)
+	}
+
+	if !rebuild {
+		if orphaned := len(c.DirectoryReport().Orphaned); orphaned > 0 {
+			fmt.Printf("Orphaned files:     %d (run cache verify -rebuild to restore them)\n", orphaned)
+		}
+		if len(report.Corrupted) > 0 {
+			return fmt.Errorf("%d corrupted entries quarantined", len(report.Corrupted))
+		}
+		return nil
+	}
+
+	rebuilt, err := c.RebuildIndex()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Recovered entries:  %d\n", len(rebuilt.Recovered))
+	for _, key := range rebuilt.Recovered {
+		fmt.Printf("  %s\n", key)
+	}
+	fmt.Printf("Corrupted orphans:  %d\n", len(rebuilt.Corrupted))
+	for _, name := range rebuilt.Corrupted {
+		fmt.Printf("  %s\n", name)
+	}
+	fmt.Printf("Unrecoverable:      %d\n", len(rebuilt.Unrecoverable))
+	for _, name := range rebuilt.Unrecoverable {
+		fmt.Printf("  %s\n", name)
+	}
+
+	if corrupted := len(report.Corrupted) + len(rebuilt.Corrupted); corrupted > 0 {
+		return fmt.Errorf("%d corrupted files quarantined", corrupted)
+	}
+	return nil
+}
+
+// formatBytes formats a byte count using binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
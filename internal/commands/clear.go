@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/cache"
+)
+
+func clearCommand() *Command {
+	var typeName, prefix, olderThan string
+	return &Command{
+		Name:    "clear",
+		Args:    "[key...]",
+		Summary: "Clear the whole cache, or only the given keys or matching entries",
+		Help:    "Arguments:\n  key - Exact cache key to remove, e.g. a proxy list URL",
+		MaxArgs: -1,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&typeName, "type", "", "Only remove entries of this type (scalar, list, web, scan_result)")
+			fs.StringVar(&prefix, "prefix", "", "Only remove entries whose key starts with this prefix, e.g. scan/socks5/")
+			fs.StringVar(&olderThan, "older-than", "", "Only remove entries not updated within this age, e.g. 12h or 7d")
+		},
+		Run: func(env *Env, keys []string) error {
+			return clearCache(env.Cache, keys, typeName, prefix, olderThan)
+		},
+	}
+}
+
+// clearCache removes cache entries. Without keys or filters the whole cache
+// directory is wiped; otherwise only the given keys, or the entries
+// matching the type, prefix and age filters, are removed.
+func clearCache(c *cache.Cache, keys []string, typeName, prefix, olderThan string) error {
+	filtered := typeName != "" || prefix != "" || olderThan != ""
+
+	switch {
+	case len(keys) > 0 && filtered:
+		return usageErrorf("keys cannot be combined with -type, -prefix or -older-than")
+
+	case len(keys) > 0:
+		for _, key := range keys {
+			if err := c.Delete(key); err != nil {
+				return err
+			}
+			fmt.Printf("Removed %s\n", key)
+		}
+
+	case filtered:
+		filter := cache.Filter{Prefix: prefix}
+		if typeName != "" {
+			var err error
+			if filter.Type, err = cache.ParseEntryType(typeName); err != nil {
+				return usageErrorf("%v", err)
+			}
+		}
+		if olderThan != "" {
+			var err error
+			if filter.OlderThan, err = parseAge(olderThan); err != nil {
+				return usageErrorf("%v", err)
+			}
+		}
+
+		removed, err := c.Purge(filter)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d cache entries\n", removed)
+
+	default:
+		fmt.Println("Clearing cache...")
+		if err := c.Clear(); err != nil {
+			return fmt.Errorf("failed to clear cache: %w", err)
+		}
+		fmt.Println("Cache cleared successfully")
+	}
+
+	return nil
+}
+
+// parseAge parses a duration that may also be given in days, e.g. "7d".
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
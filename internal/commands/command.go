@@ -0,0 +1,272 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"free-proxy-list-speed-checker/internal/cache"
+	"free-proxy-list-speed-checker/internal/config"
+	"free-proxy-list-speed-checker/internal/output"
+)
+
+// Env is what a command runs against. Cache is nil for commands that do
+// not use it. Output is the format selected with -output.
+type Env struct {
+	Config *config.Config
+	Cache  *cache.Cache
+	Output output.Format
+}
+
+// write prints records to stdout in the machine-readable output format.
+func (env *Env) write(records any) error {
+	return output.Write(os.Stdout, env.Output, records)
+}
+
+// Globals holds the flags accepted anywhere on the command line.
+type Globals struct {
+	ConfigPath string
+	Offline    bool
+	Output     string
+}
+
+func (g *Globals) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.ConfigPath, "config", "config.toml", "Config file, looked up in config/ unless a path is given")
+	fs.BoolVar(&g.Offline, "offline", false, "Never access the network; use cached proxy lists only")
+	fs.StringVar(&g.Output, "output", "text", "Output format: text, json, jsonl, csv or yaml")
+}
+
+// Opener builds the environment of a command from the global flags. The
+// cache is only opened when withCache is set.
+type Opener func(globals Globals, withCache bool) (*Env, error)
+
+// Command is a subcommand with its own flags, positional arguments and
+// help. A command either runs or dispatches to its subcommands.
+type Command struct {
+	Name string
+	// Args describes the positional arguments in the usage line.
+	Args    string
+	Summary string
+	// Help is printed below the summary by help <command>.
+	Help string
+	// MinArgs and MaxArgs bound the number of positional arguments. A
+	// negative MaxArgs allows any number.
+	MinArgs int
+	MaxArgs int
+	// NoCache runs the command without opening the cache.
+	NoCache bool
+	// Output marks commands that support the machine-readable formats of
+	// -output. Other commands only accept text.
+	Output      bool
+	Flags       func(fs *flag.FlagSet)
+	Run         func(env *Env, args []string) error
+	Subcommands []*Command
+}
+
+// UsageError reports invalid arguments. The usage of the command is shown
+// along with it.
+type UsageError struct {
+	msg string
+}
+
+func (e *UsageError) Error() string {
+	return e.msg
+}
+
+func usageErrorf(format string, a ...any) error {
+	return &UsageError{msg: fmt.Sprintf(format, a...)}
+}
+
+// commandList returns the top-level commands in the order of the usage.
+func commandList() []*Command {
+	return []*Command{
+		listCommand(),
+		scanCommand(),
+		statsCommand(),
+		getFastCommand(),
+		exportCommand(),
+		serveCommand(),
+		apiCommand(),
+		cacheCommand(),
+		clearCommand(),
+	}
+}
+
+func find(commands []*Command, name string) *Command {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// Execute runs the command selected by args and returns the exit status:
+// 0 on success, 1 when the command fails and 2 for invalid usage.
+func Execute(args []string, open Opener) int {
+	var globals Globals
+	globalFlags := flag.NewFlagSet("program", flag.ContinueOnError)
+	globals.register(globalFlags)
+	globalFlags.Usage = func() {
+		printUsage(globalFlags.Output())
+	}
+
+	i := firstPositional(globalFlags, args)
+	if i < 0 {
+		if err := globalFlags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 0
+			}
+			return 2
+		}
+		printUsage(os.Stdout)
+		return 0
+	}
+
+	name := args[i]
+	rest := append(args[:i:i], args[i+1:]...)
+
+	if name == "help" {
+		names, err := parseArgs(globalFlags, rest)
+		if err != nil {
+			return 2
+		}
+		return help(names)
+	}
+
+	cmd := find(commandList(), name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", name)
+		printUsage(os.Stderr)
+		return 2
+	}
+	path := []*Command{cmd}
+
+	for len(cmd.Subcommands) > 0 {
+		i := firstPositional(globalFlags, rest)
+		if i < 0 {
+			fmt.Fprintf(os.Stderr, "Error: %s needs a subcommand\n\n", commandPath(path))
+			printHelp(os.Stderr, path)
+			return 2
+		}
+		sub := find(cmd.Subcommands, rest[i])
+		if sub == nil {
+			fmt.Fprintf(os.Stderr, "Error: unknown %s subcommand: %s\n\n", commandPath(path), rest[i])
+			printHelp(os.Stderr, path)
+			return 2
+		}
+		rest = append(rest[:i:i], rest[i+1:]...)
+		cmd = sub
+		path = append(path, cmd)
+	}
+
+	fs := flag.NewFlagSet(commandPath(path), flag.ContinueOnError)
+	globals.register(fs)
+	if cmd.Flags != nil {
+		cmd.Flags(fs)
+	}
+	fs.Usage = func() {
+		printHelp(fs.Output(), path)
+	}
+
+	positional, err := parseArgs(fs, rest)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	if len(positional) < cmd.MinArgs || (cmd.MaxArgs >= 0 && len(positional) > cmd.MaxArgs) {
+		fmt.Fprintf(os.Stderr, "Error: unexpected number of arguments: %d\n\n", len(positional))
+		printHelp(os.Stderr, path)
+		return 2
+	}
+
+	format, err := output.ParseFormat(globals.Output)
+	if err == nil && format != output.Text && !cmd.Output {
+		err = fmt.Errorf("%s does not support -output %s", commandPath(path), format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		printHelp(os.Stderr, path)
+		return 2
+	}
+
+	env, err := open(globals, !path[0].NoCache)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	env.Output = format
+	if env.Cache != nil {
+		defer func() {
+			if err := env.Cache.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: cache close failed: %v\n", err)
+			}
+		}()
+	}
+
+	if err := cmd.Run(env, positional); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		var usageErr *UsageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprintf(os.Stderr, "\n")
+			printHelp(os.Stderr, path)
+			return 2
+		}
+		return 1
+	}
+
+	return 0
+}
+
+// firstPositional returns the index of the first argument that is neither
+// a global flag nor the value of one, or -1 if there is none.
+func firstPositional(globalFlags *flag.FlagSet, args []string) int {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			if i+1 < len(args) {
+				return i + 1
+			}
+			return -1
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return i
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		if f := globalFlags.Lookup(name); f != nil && !isBoolFlag(f) {
+			i++
+		}
+	}
+	return -1
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func commandPath(path []*Command) string {
+	names := make([]string, len(path))
+	for i, cmd := range path {
+		names[i] = cmd.Name
+	}
+	return strings.Join(names, " ")
+}
+
+// printFlags prints the defaults of the flags registered by register.
+func printFlags(w io.Writer, register func(fs *flag.FlagSet)) {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(w)
+	register(fs)
+	fs.PrintDefaults()
+}
@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"slices"
+	"testing"
+
+	"free-proxy-list-speed-checker/internal/cache"
+	"free-proxy-list-speed-checker/internal/config"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{nil, nil},
+		{[]string{"a", "b"}, []string{"a", "b"}},
+		{[]string{"-n", "1", "a"}, []string{"a"}},
+		{[]string{"a", "-n", "1", "b", "-v"}, []string{"a", "b"}},
+		{[]string{"--", "-n", "a"}, []string{"-n", "a"}},
+		{[]string{"a", "-v", "--", "b", "-x"}, []string{"a", "b", "-x"}},
+		{[]string{"-n", "1", "--"}, nil},
+	}
+
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Int("n", 0, "")
+		fs.Bool("v", false, "")
+
+		got, err := parseArgs(fs, tt.args)
+		if err != nil {
+			t.Errorf("parseArgs(%q) failed: %v", tt.args, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestFirstPositional(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{nil, -1},
+		{[]string{"list"}, 0},
+		{[]string{"-offline", "list"}, 1},
+		{[]string{"-config", "c.toml", "list"}, 2},
+		{[]string{"--config=c.toml", "list"}, 1},
+		{[]string{"-output", "json"}, -1},
+		{[]string{"--", "list"}, 1},
+		{[]string{"--"}, -1},
+		{[]string{"-", "list"}, 0},
+	}
+
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		new(Globals).register(fs)
+
+		if got := firstPositional(fs, tt.args); got != tt.want {
+			t.Errorf("firstPositional(%q) = %d, want %d", tt.args, got, tt.want)
+		}
+	}
+}
+
+// quiet discards what the commands print for the rest of the test.
+func quiet(t *testing.T) {
+	t.Helper()
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", os.DevNull, err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = devNull, devNull
+	t.Cleanup(func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		devNull.Close()
+	})
+}
+
+func TestExecute(t *testing.T) {
+	quiet(t)
+
+	tests := []struct {
+		name    string
+		args    []string
+		openErr error
+		want    int
+		// globals are checked when the command was run.
+		globals *Globals
+	}{
+		{name: "usage", args: nil, want: 0},
+		{name: "global help", args: []string{"-h"}, want: 0},
+		{name: "unknown global flag", args: []string{"-bogus", "list"}, want: 2},
+		{name: "unknown command", args: []string{"bogus"}, want: 2},
+
+		{name: "command", args: []string{"list"}, want: 0,
+			globals: &Globals{ConfigPath: "config.toml", Output: "text"}},
+		{name: "global flags before the command", args: []string{"-config", "c.toml", "-offline", "list"}, want: 0,
+			globals: &Globals{ConfigPath: "c.toml", Offline: true, Output: "text"}},
+		{name: "global flags after the command", args: []string{"list", "-output", "json", "-config=c.toml"}, want: 0,
+			globals: &Globals{ConfigPath: "c.toml", Output: "json"}},
+		{name: "command help", args: []string{"list", "-h"}, want: 0},
+		{name: "too many arguments", args: []string{"list", "extra"}, want: 2},
+		{name: "unsupported output", args: []string{"-output", "json", "clear"}, want: 2},
+		{name: "invalid output", args: []string{"list", "-output", "xml"}, want: 2},
+
+		{name: "help", args: []string{"help"}, want: 0},
+		{name: "help command", args: []string{"help", "scan"}, want: 0},
+		{name: "help subcommand", args: []string{"help", "cache", "verify"}, want: 0},
+		{name: "help unknown subcommand", args: []string{"help", "cache", "bogus"}, want: 2},
+		{name: "help unknown command", args: []string{"-offline", "help", "bogus"}, want: 2},
+
+		{name: "subcommand", args: []string{"cache", "ls"}, want: 0,
+			globals: &Globals{ConfigPath: "config.toml", Output: "text"}},
+		{name: "global flag before subcommand", args: []string{"cache", "-offline", "stats"}, want: 0,
+			globals: &Globals{ConfigPath: "config.toml", Offline: true, Output: "text"}},
+		{name: "subcommand flag", args: []string{"cache", "verify", "-rebuild"}, want: 0},
+		{name: "missing subcommand", args: []string{"cache"}, want: 2},
+		{name: "unknown subcommand", args: []string{"cache", "bogus"}, want: 2},
+		{name: "missing argument", args: []string{"cache", "show"}, want: 2},
+
+		{name: "run error", args: []string{"cache", "show", "missing"}, want: 1},
+		{name: "flags end at --", args: []string{"clear", "--", "missing", "-x"}, want: 1},
+		{name: "usage error from run", args: []string{"clear", "key", "-type", "web"}, want: 2},
+		{name: "open error", args: []string{"list"}, openErr: errors.New("no config"), want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opened *Globals
+			open := func(globals Globals, withCache bool) (*Env, error) {
+				if tt.openErr != nil {
+					return nil, tt.openErr
+				}
+				opened = &globals
+
+				env := &Env{Config: &config.Config{}}
+				if withCache {
+					c, err := cache.New(t.TempDir())
+					if err != nil {
+						return nil, err
+					}
+					env.Cache = c
+				}
+				return env, nil
+			}
+
+			if got := Execute(tt.args, open); got != tt.want {
+				t.Fatalf("Execute(%q) = %d, want %d", tt.args, got, tt.want)
+			}
+			if tt.globals != nil && (opened == nil || *opened != *tt.globals) {
+				t.Errorf("Execute(%q) opened %+v, want %+v", tt.args, opened, tt.globals)
+			}
+		})
+	}
+}
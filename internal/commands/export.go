@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"free-proxy-list-speed-checker/internal/export"
+	"free-proxy-list-speed-checker/internal/network"
+)
+
+func exportCommand() *Command {
+	var format, file string
+
+	var formats strings.Builder
+	formats.WriteString("Formats:\n")
+	for _, f := range export.Formats() {
+		fmt.Fprintf(&formats, "  %-11s - %s\n", f.Name, f.Description)
+	}
+
+	return &Command{
+		Name:    "export",
+		Args:    "[collection] [number]",
+		Summary: "Export the fastest proxies as proxy client configuration",
+		Help: "Arguments:\n" +
+			"  collection - Name of the collection (default: socks5)\n" +
+			"  number     - Number of proxies to export (default: all working)\n\n" +
+			strings.TrimSuffix(formats.String(), "\n"),
+		MaxArgs: 2,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&format, "format", "plain", "Export format, see above")
+			fs.StringVar(&file, "file", "", "Write to this file instead of stdout")
+		},
+		Run: func(env *Env, args []string) error {
+			if _, ok := export.Lookup(format); !ok {
+				return usageErrorf("unknown export format %q", format)
+			}
+			number, err := numberArg(args, 0)
+			if err != nil {
+				return err
+			}
+			return exportProxies(env, collectionArg(args), number, format, file)
+		},
+	}
+}
+
+// exportProxies renders the top ranked working proxies of a collection in
+// an export format. A number of 0 exports all of them.
+func exportProxies(env *Env, collection string, number int, format, file string) error {
+	if _, err := lookupCollection(env.Config, collection); err != nil {
+		return err
+	}
+
+	ranked, err := network.RankCollection(env.Cache, collection, env.Config.Ranking)
+	if err != nil {
+		return fmt.Errorf("%w; run 'scan %s' first", err, collection)
+	}
+
+	if len(ranked) == 0 {
+		return fmt.Errorf("no working proxies in the latest scan of %s", collection)
+	}
+	if number > 0 {
+		ranked = ranked[:min(number, len(ranked))]
+	}
+
+	entries := make([]export.Entry, len(ranked))
+	for i, r := range ranked {
+		entries[i] = export.Entry{
+			Name:  fmt.Sprintf("%s-%d", collection, i+1),
+			Proxy: r.Proxy,
+		}
+	}
+
+	if file == "" {
+		_, err := writeExport(os.Stdout, format, entries)
+		return err
+	}
+
+	// Write to a temporary file next to the target and rename it, so a
+	// failed export never leaves a partial or truncated file behind.
+	f, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	exported, err := writeExport(f, format, entries)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	if err := os.Rename(f.Name(), file); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	log.Printf("Exported %d proxies to %s", exported, file)
+
+	return nil
+}
+
+// writeExport renders entries and warns about the ones the format cannot
+// express. It returns the number of exported entries.
+func writeExport(w io.Writer, format string, entries []export.Entry) (int, error) {
+	skipped, err := export.Write(w, format, entries)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range skipped {
+		log.Printf("warning: %s cannot be exported as %s, skipped", e.Proxy, format)
+	}
+
+	return len(entries) - len(skipped), nil
+}
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"free-proxy-list-speed-checker/internal/cache"
+	"free-proxy-list-speed-checker/internal/config"
+	"free-proxy-list-speed-checker/internal/network"
+)
+
+func TestExportFile(t *testing.T) {
+	c, err := cache.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	// Clash cannot express SOCKS4 proxies.
+	report := testReport("socks4")
+	for i := range report.Results {
+		report.Results[i].Proxy.Scheme = "socks4"
+	}
+	if err := network.SaveReport(c, report); err != nil {
+		t.Fatalf("SaveReport failed: %v", err)
+	}
+	env := &Env{
+		Config: &config.Config{
+			ProxyCollectionList: config.ProxyCollectionList{
+				"socks4": {Url: "http://127.0.0.1:9/socks4.txt", Protocol: "socks4", Enabled: true},
+			},
+		},
+		Cache: c,
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "proxies.txt")
+	if err := os.WriteFile(file, []byte("previous export\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if err := exportProxies(env, "socks4", 0, "clash", file); err == nil {
+		t.Fatal("Expected an error when no proxy can be exported")
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "previous export\n" {
+		t.Errorf("Expected the previous export to be kept, got %q, %v", data, err)
+	}
+
+	if err := exportProxies(env, "socks4", 0, "plain", file); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "127.0.0.1:1081\n127.0.0.1:1080\n" {
+		t.Errorf("Unexpected export: %q, %v", data, err)
+	}
+
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected no temporary files to be left, got %d files", len(files))
+	}
+}
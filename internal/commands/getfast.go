@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"free-proxy-list-speed-checker/internal/export"
+	"free-proxy-list-speed-checker/internal/network"
+	"free-proxy-list-speed-checker/internal/output"
+)
+
+func getFastCommand() *Command {
+	return &Command{
+		Name:    "get-fast",
+		Args:    "[collection] [number]",
+		Summary: "Get the fastest proxy servers from a collection",
+		Help: "Prints one proxy URL per line, so the output can be piped into other tools.\n\n" +
+			"Arguments:\n" +
+			"  collection - Name of the collection (default: socks5)\n" +
+			"  number     - Number of proxies to retrieve (default: 1)",
+		MaxArgs: 2,
+		Output:  true,
+		Run: func(env *Env, args []string) error {
+			number, err := numberArg(args, 1)
+			if err != nil {
+				return err
+			}
+			return getFast(env, collectionArg(args), number)
+		},
+	}
+}
+
+// numberArg returns the number of proxies given as the second positional
+// argument, or def if it is absent.
+func numberArg(args []string, def int) (int, error) {
+	if len(args) < 2 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 1 {
+		return 0, usageErrorf("number must be a positive integer, got %q", args[1])
+	}
+	return n, nil
+}
+
+// getFast prints the top ranked working proxies of a collection, one URL
+// per line. Diagnostics go to stderr.
+func getFast(env *Env, collection string, number int) error {
+	if _, err := lookupCollection(env.Config, collection); err != nil {
+		return err
+	}
+
+	ranked, err := network.RankCollection(env.Cache, collection, env.Config.Ranking)
+	if err != nil {
+		return fmt.Errorf("%w; run 'scan %s' first", err, collection)
+	}
+
+	if len(ranked) == 0 {
+		return fmt.Errorf("no working proxies in the latest scan of %s", collection)
+	}
+
+	ranked = ranked[:min(number, len(ranked))]
+	if env.Output != output.Text {
+		records := make([]rankedRecord, len(ranked))
+		for i, r := range ranked {
+			records[i] = newRankedRecord(i+1, r)
+		}
+		return env.write(records)
+	}
+
+	for _, r := range ranked {
+		fmt.Println(export.ClientURL(r.Proxy))
+	}
+
+	return nil
+}
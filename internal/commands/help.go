@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Free Proxy List Speed Checker")
+	fmt.Fprintln(w, "\nUsage:")
+	fmt.Fprintln(w, "  program [global flags] <command> [flags] [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commandList() {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Fprintf(w, "  %-10s %s\n", "help", "Show the flags and arguments of a command")
+	fmt.Fprintln(w, "\nGlobal flags, accepted before or after the command:")
+	printFlags(w, new(Globals).register)
+	fmt.Fprintln(w, "\nExamples:")
+	fmt.Fprintln(w, "  program list")
+	fmt.Fprintln(w, "  program scan socks5")
+	fmt.Fprintln(w, "  program stats")
+	fmt.Fprintln(w, "  program get-fast socks5 5")
+	fmt.Fprintln(w, "  program export -format proxychains socks5 10")
+	fmt.Fprintln(w, "  program serve -listen 127.0.0.1:1080 -strategy sticky socks5")
+	fmt.Fprintln(w, "  program api -listen 127.0.0.1:8080")
+	fmt.Fprintln(w, "  program cache ls")
+	fmt.Fprintln(w, "  program clear -type web -older-than 7d")
+	fmt.Fprintln(w, "  program help scan")
+}
+
+// printHelp prints the usage of the last command in path.
+func printHelp(w io.Writer, path []*Command) {
+	cmd := path[len(path)-1]
+
+	usage := "program " + commandPath(path)
+	switch {
+	case len(cmd.Subcommands) > 0:
+		usage += " <subcommand>"
+	case cmd.Flags != nil:
+		usage += " [flags]"
+	}
+	if cmd.Args != "" {
+		usage += " " + cmd.Args
+	}
+
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n", usage, cmd.Summary)
+	if cmd.Help != "" {
+		fmt.Fprintf(w, "\n%s\n", cmd.Help)
+	}
+
+	if len(cmd.Subcommands) > 0 {
+		fmt.Fprintln(w, "\nSubcommands:")
+		for _, sub := range cmd.Subcommands {
+			fmt.Fprintf(w, "  %-8s %s\n", sub.Name, sub.Summary)
+		}
+	}
+
+	if cmd.Flags != nil {
+		fmt.Fprintln(w, "\nFlags:")
+		printFlags(w, cmd.Flags)
+	}
+
+	fmt.Fprintln(w, "\nGlobal flags:")
+	printFlags(w, new(Globals).register)
+}
+
+// help prints the usage of the command named by args, or the general usage
+// without arguments.
+func help(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return 0
+	}
+
+	commands := commandList()
+	var path []*Command
+	for _, name := range args {
+		cmd := find(commands, name)
+		if cmd == nil {
+			fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", name)
+			printUsage(os.Stderr)
+			return 2
+		}
+		path = append(path, cmd)
+		commands = cmd.Subcommands
+	}
+
+	printHelp(os.Stdout, path)
+	return 0
+}
@@ -2,23 +2,63 @@ package commands
 
 import (
 	"fmt"
-	"reflect"
 	"strings"
 
 	"free-proxy-list-speed-checker/internal/config"
+	"free-proxy-list-speed-checker/internal/output"
 )
 
-func List(cfg *config.Config) {
+func listCommand() *Command {
+	return &Command{
+		Name:    "list",
+		Summary: "List all available proxy server collections",
+		NoCache: true,
+		Output:  true,
+		Run: func(env *Env, args []string) error {
+			return list(env)
+		},
+	}
+}
+
+func list(env *Env) error {
+	cfg := env.Config
+	if env.Output != output.Text {
+		records := []collectionRecord{}
+		for _, name := range cfg.ProxyCollectionList.Names() {
+			records = append(records, newCollectionRecord(name, cfg.ProxyCollectionList[name]))
+		}
+		return env.write(records)
+	}
+
 	fmt.Println("Available proxy collections:")
-	v := reflect.ValueOf(cfg.ProxyCollectionList)
-	t := v.Type()
-	for i := 0; i < v.NumField(); i++ {
-		field := t.Field(i)
-		tagValue := field.Tag.Get("toml")
-		if tagValue != "" {
-			fmt.Printf("  - %s\n", tagValue)
-		} else {
-			fmt.Printf("  - %s\n", strings.ToLower(field.Name))
+	for _, name := range cfg.ProxyCollectionList.Names() {
+		collection := cfg.ProxyCollectionList[name]
+		if !collection.Enabled {
+			fmt.Printf("  - %s (%s, disabled)\n", name, collection.Protocol)
+			continue
 		}
+		fmt.Printf("  - %s (%s)\n", name, collection.Protocol)
+	}
+
+	return nil
+}
+
+// lookupCollection returns the named collection, or an error listing the
+// available ones.
+func lookupCollection(cfg *config.Config, name string) (config.Collection, error) {
+	collection, ok := cfg.ProxyCollectionList[name]
+	if !ok {
+		return collection, fmt.Errorf("collection '%s' not found; available: %s",
+			name, strings.Join(cfg.ProxyCollectionList.Names(), ", "))
+	}
+	return collection, nil
+}
+
+// collectionArg returns the collection named by the first positional
+// argument, socks5 by default.
+func collectionArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
 	}
+	return "socks5"
 }
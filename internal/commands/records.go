@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"time"
+
+	"free-proxy-list-speed-checker/internal/config"
+	"free-proxy-list-speed-checker/internal/export"
+	"free-proxy-list-speed-checker/internal/network"
+)
+
+// The types below are the schemas of the machine-readable output selected
+// with -output. They are documented in the README; renaming or removing a
+// field breaks the scripts that consume it. Durations are milliseconds and
+// throughput is bytes per second.
+
+// collectionRecord is written by list, one per collection.
+type collectionRecord struct {
+	Name              string  `json:"name"`
+	Protocol          string  `json:"protocol"`
+	URL               string  `json:"url"`
+	Enabled           bool    `json:"enabled"`
+	RefreshIntervalMs float64 `json:"refresh_interval_ms"`
+}
+
+func newCollectionRecord(name string, c config.Collection) collectionRecord {
+	return collectionRecord{
+		Name:              name,
+		Protocol:          c.Protocol,
+		URL:               c.Url,
+		Enabled:           c.Enabled,
+		RefreshIntervalMs: millis(c.RefreshInterval),
+	}
+}
+
+// speedRecord is the download test of a proxy.
+type speedRecord struct {
+	TTFBMs      float64 `json:"ttfb_ms"`
+	TotalMs     float64 `json:"total_ms"`
+	Bytes       int64   `json:"bytes"`
+	BytesPerSec float64 `json:"bytes_per_sec"`
+	Error       string  `json:"error"`
+}
+
+// resultRecord is written by scan, one per proxy of the list.
+type resultRecord struct {
+	Proxy       string         `json:"proxy"`
+	Scheme      string         `json:"scheme"`
+	Host        string         `json:"host"`
+	Port        int            `json:"port"`
+	Connected   bool           `json:"connected"`
+	Handshake   bool           `json:"handshake"`
+	OK          bool           `json:"ok"`
+	Modes       []network.Mode `json:"modes"`
+	LatencyMs   float64        `json:"latency_ms"`
+	FailedStage network.Stage  `json:"failed_stage"`
+	Error       string         `json:"error"`
+	Speed       speedRecord    `json:"speed"`
+	CheckedAt   time.Time      `json:"checked_at"`
+}
+
+func newResultRecord(r network.Result) resultRecord {
+	return resultRecord{
+		Proxy:       r.Proxy.String(),
+		Scheme:      r.Proxy.Scheme,
+		Host:        r.Proxy.Host,
+		Port:        r.Proxy.Port,
+		Connected:   r.Connected,
+		Handshake:   r.Handshake,
+		OK:          r.OK,
+		Modes:       append([]network.Mode{}, r.Modes...),
+		LatencyMs:   millis(r.Latency),
+		FailedStage: r.FailedStage,
+		Error:       r.Error,
+		Speed: speedRecord{
+			TTFBMs:      millis(r.Speed.TTFB),
+			TotalMs:     millis(r.Speed.Total),
+			Bytes:       r.Speed.Bytes,
+			BytesPerSec: r.Speed.BytesPerSec,
+			Error:       r.SpeedError,
+		},
+		CheckedAt: r.CheckedAt,
+	}
+}
+
+// rankedRecord is written by get-fast, one per proxy, fastest first.
+type rankedRecord struct {
+	Rank         int       `json:"rank"`
+	Proxy        string    `json:"proxy"`
+	Scheme       string    `json:"scheme"`
+	Host         string    `json:"host"`
+	Port         int       `json:"port"`
+	LatencyMs    float64   `json:"latency_ms"`
+	BytesPerSec  float64   `json:"bytes_per_sec"`
+	Score        float64   `json:"score"`
+	SuccessRatio float64   `json:"success_ratio"`
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+func newRankedRecord(rank int, r network.Ranked) rankedRecord {
+	return rankedRecord{
+		Rank:         rank,
+		Proxy:        export.ClientURL(r.Proxy),
+		Scheme:       r.Proxy.Scheme,
+		Host:         r.Proxy.Host,
+		Port:         r.Proxy.Port,
+		LatencyMs:    millis(r.Latency),
+		BytesPerSec:  r.Speed.BytesPerSec,
+		Score:        r.Score,
+		SuccessRatio: r.SuccessRatio,
+		CheckedAt:    r.CheckedAt,
+	}
+}
+
+// stageCounts is the number of proxies that failed at each check stage. It
+// has a fixed set of columns so that the CSV header does not depend on
+// which stages failed.
+type stageCounts struct {
+	Dial     int `json:"dial"`
+	Greeting int `json:"greeting"`
+	Auth     int `json:"auth"`
+	Connect  int `json:"connect"`
+	Request  int `json:"request"`
+	TLS      int `json:"tls"`
+}
+
+// percentileRecord is the distribution of a sample.
+type percentileRecord struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+}
+
+// statsRecord is written by stats, one for the collection.
+type statsRecord struct {
+	Collection  string           `json:"collection"`
+	ScannedAt   time.Time        `json:"scanned_at"`
+	DurationMs  float64          `json:"duration_ms"`
+	Parsed      int              `json:"parsed"`
+	Malformed   int              `json:"malformed"`
+	Reachable   int              `json:"reachable"`
+	Handshake   int              `json:"handshake"`
+	Working     int              `json:"working"`
+	Failures    stageCounts      `json:"failures"`
+	LatencyMs   percentileRecord `json:"latency_ms"`
+	BytesPerSec percentileRecord `json:"bytes_per_sec"`
+}
+
+func newStatsRecord(collection string, report *network.Report, scannedAt time.Time, s network.Summary) statsRecord {
+	record := statsRecord{
+		Collection: collection,
+		ScannedAt:  scannedAt,
+		DurationMs: millis(report.FinishedAt.Sub(report.StartedAt)),
+		Parsed:     s.Parsed,
+		Malformed:  s.Malformed,
+		Reachable:  s.Reachable,
+		Handshake:  s.Handshake,
+		Working:    s.Working,
+		LatencyMs: percentileRecord{
+			Count: s.Latency.Count,
+			P50:   millis(s.Latency.P50),
+			P90:   millis(s.Latency.P90),
+			P99:   millis(s.Latency.P99),
+		},
+		BytesPerSec: percentileRecord{
+			Count: s.Throughput.Count,
+			P50:   s.Throughput.P50,
+			P90:   s.Throughput.P90,
+			P99:   s.Throughput.P99,
+		},
+	}
+
+	for _, f := range s.Failures {
+		switch f.Stage {
+		case network.StageDial:
+			record.Failures.Dial = f.Count
+		case network.StageGreeting:
+			record.Failures.Greeting = f.Count
+		case network.StageAuth:
+			record.Failures.Auth = f.Count
+		case network.StageConnect:
+			record.Failures.Connect = f.Count
+		case network.StageRequest:
+			record.Failures.Request = f.Count
+		case network.StageTLS:
+			record.Failures.TLS = f.Count
+		}
+	}
+
+	return record
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
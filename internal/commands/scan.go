@@ -1,43 +1,124 @@
 package commands
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"os"
-	"reflect"
+	"log"
+	"time"
 
-	"free-proxy-list-speed-checker/internal/config"
 	"free-proxy-list-speed-checker/internal/network"
+	"free-proxy-list-speed-checker/internal/output"
 )
 
-func Scan(cfg *config.Config) {
-	collection := "socks5"
-	if len(os.Args) > 2 {
-		collection = os.Args[2]
+func scanCommand() *Command {
+	var force bool
+	return &Command{
+		Name:    "scan",
+		Args:    "[collection]",
+		Summary: "Scan a proxy server collection for speed testing",
+		Help:    "Arguments:\n  collection - Name of the collection (default: socks5)",
+		MaxArgs: 1,
+		Output:  true,
+		Flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&force, "force", false, "Revalidate the proxy list even if the cached copy is fresh")
+		},
+		Run: func(env *Env, args []string) error {
+			return scan(env, collectionArg(args), force)
+		},
 	}
+}
 
-	if !collectionExists(collection, cfg) {
-		fmt.Printf("Error: collection '%s' not found\n", collection)
-		fmt.Println("\nAvailable collections:")
-		List(cfg)
-		os.Exit(1)
+func scan(env *Env, collection string, force bool) error {
+	if err := checkScannable(env, collection); err != nil {
+		return err
 	}
 
-	if err := network.Scan(collection, cfg); err != nil {
-		fmt.Printf("Error during scan: %v\n", err)
-		os.Exit(1)
+	if env.Output == output.Text {
+		fmt.Printf("Starting scan for collection: %s\n", collection)
+	} else {
+		log.Printf("Starting scan for collection: %s", collection)
+	}
+
+	report, err := runScan(context.Background(), env, collection, force)
+	if err != nil {
+		return err
+	}
+
+	if env.Output != output.Text {
+		records := make([]resultRecord, len(report.Results))
+		for i, r := range report.Results {
+			records[i] = newResultRecord(r)
+		}
+		return env.write(records)
+	}
+
+	connected, handshakes, working := 0, 0, 0
+	modes := map[network.Mode]int{}
+	for _, r := range report.Results {
+		if r.Connected {
+			connected++
+		}
+		if r.Handshake {
+			handshakes++
+		}
+		if r.OK {
+			working++
+		}
+		for _, m := range r.Modes {
+			modes[m]++
+		}
 	}
-}
 
-func collectionExists(collection string, cfg *config.Config) bool {
-	v := reflect.ValueOf(cfg.ProxyCollectionList)
-	t := v.Type()
+	fmt.Printf("Scan completed in %s\n", report.FinishedAt.Sub(report.StartedAt).Round(time.Millisecond))
+	fmt.Printf("  proxies:   %d\n", len(report.Results))
+	fmt.Printf("  malformed: %d\n", report.Malformed)
+	fmt.Printf("  reachable: %d\n", connected)
+	fmt.Printf("  handshake: %d\n", handshakes)
+	fmt.Printf("  working:   %d\n", working)
+	if len(modes) > 0 {
+		fmt.Printf("  get:       %d\n", modes[network.ModeGet])
+		fmt.Printf("  connect:   %d\n", modes[network.ModeConnect])
+	}
 
-	for i := 0; i < v.NumField(); i++ {
-		field := t.Field(i)
-		tagValue := field.Tag.Get("toml")
-		if tagValue == collection {
-			return true
+	ranked := network.Rank([]*network.Report{report}, env.Config.Ranking)
+	if len(ranked) > 5 {
+		ranked = ranked[:5]
+	}
+	if len(ranked) > 0 {
+		fmt.Println("Fastest proxies:")
+		for _, r := range ranked {
+			fmt.Printf("  %-45s %10.1f KiB/s %8s\n", r.Proxy, r.Speed.BytesPerSec/1024, r.Latency.Round(time.Millisecond))
 		}
 	}
-	return false
+
+	return nil
+}
+
+// checkScannable returns an error unless the collection exists and is
+// enabled.
+func checkScannable(env *Env, collection string) error {
+	col, err := lookupCollection(env.Config, collection)
+	if err != nil {
+		return err
+	}
+	if !col.Enabled {
+		return fmt.Errorf("collection '%s' is disabled", collection)
+	}
+	return nil
+}
+
+// runScan scans a collection and stores the report in the cache.
+func runScan(ctx context.Context, env *Env, collection string, force bool) (*network.Report, error) {
+	opts := network.ScanOptions{ForceRefresh: force}
+	report, err := network.Scan(ctx, collection, env.Config, env.Cache, opts)
+	if err != nil {
+		return nil, fmt.Errorf("scan failed: %w", err)
+	}
+
+	if err := network.SaveReport(env.Cache, report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
 }
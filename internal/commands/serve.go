@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/gateway"
+	"free-proxy-list-speed-checker/internal/network"
+)
+
+func serveCommand() *Command {
+	var (
+		listen    string
+		strategy  string
+		upstreams int
+		attempts  int
+		timeout   time.Duration
+		cooldown  time.Duration
+	)
+	return &Command{
+		Name:    "serve",
+		Args:    "[collection]",
+		Summary: "Run a local SOCKS5 and HTTP CONNECT proxy forwarding through the fastest proxies",
+		Help: "The gateway accepts SOCKS5 and HTTP CONNECT clients on the same address and\n" +
+			"tunnels every connection through a working proxy of the latest scan. A\n" +
+			"connection whose proxy fails before the destination answers is retried\n" +
+			"through the next one.\n\n" +
+			"Arguments:\n" +
+			"  collection - Name of the collection (default: socks5)",
+		MaxArgs: 1,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&listen, "listen", "127.0.0.1:1080", "Address to listen on")
+			fs.StringVar(&strategy, "strategy", string(gateway.RoundRobin), "Upstream selection: round-robin, least-latency or sticky")
+			fs.IntVar(&upstreams, "upstreams", 0, "Use only the N fastest proxies (default: all working)")
+			fs.IntVar(&attempts, "attempts", 3, "Proxies tried for one connection")
+			fs.DurationVar(&timeout, "timeout", 10*time.Second, "Handshake timeout for clients and proxies")
+			fs.DurationVar(&cooldown, "cooldown", time.Minute, "How long a failed proxy is skipped")
+		},
+		Run: func(env *Env, args []string) error {
+			s, err := gateway.ParseStrategy(strategy)
+			if err != nil {
+				return usageErrorf("%v", err)
+			}
+			if upstreams < 0 || attempts < 1 {
+				return usageErrorf("-upstreams must not be negative and -attempts must be positive")
+			}
+			opts := gateway.Options{
+				Strategy: s,
+				Timeout:  timeout,
+				Attempts: attempts,
+				Cooldown: cooldown,
+			}
+			return serve(env, collectionArg(args), listen, upstreams, opts)
+		},
+	}
+}
+
+// serve runs the gateway until interrupted.
+func serve(env *Env, collection, listen string, number int, opts gateway.Options) error {
+	if _, err := lookupCollection(env.Config, collection); err != nil {
+		return err
+	}
+
+	ranked, err := network.RankCollection(env.Cache, collection, env.Config.Ranking)
+	if err != nil {
+		return fmt.Errorf("%w; run 'scan %s' first", err, collection)
+	}
+	if len(ranked) == 0 {
+		return fmt.Errorf("no working proxies in the latest scan of %s", collection)
+	}
+	if number > 0 {
+		ranked = ranked[:min(number, len(ranked))]
+	}
+
+	upstreams := make([]gateway.Upstream, len(ranked))
+	for i, r := range ranked {
+		upstreams[i] = gateway.Upstream{Proxy: r.Proxy, Latency: r.Latency}
+	}
+
+	server, err := gateway.New(upstreams, opts)
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Serving SOCKS5 and HTTP CONNECT on %s through %d %s proxies (%s)",
+		l.Addr(), len(upstreams), collection, opts.Strategy)
+	if err := server.Serve(ctx, l); err != nil {
+		return fmt.Errorf("gateway failed: %w", err)
+	}
+	log.Println("Gateway stopped")
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/network"
+	"free-proxy-list-speed-checker/internal/output"
+)
+
+func statsCommand() *Command {
+	return &Command{
+		Name:    "stats",
+		Args:    "[collection]",
+		Summary: "Display available speed information for a collection",
+		Help:    "Arguments:\n  collection - Name of the collection (default: socks5)",
+		MaxArgs: 1,
+		Output:  true,
+		Run: func(env *Env, args []string) error {
+			return stats(env, collectionArg(args))
+		},
+	}
+}
+
+func stats(env *Env, collection string) error {
+	if _, err := lookupCollection(env.Config, collection); err != nil {
+		return err
+	}
+
+	report, metadata, err := network.LatestReport(env.Cache, collection)
+	if err != nil {
+		return fmt.Errorf("%w; run 'scan %s' first", err, collection)
+	}
+
+	s := network.Summarize(report)
+	if env.Output != output.Text {
+		return env.write([]statsRecord{newStatsRecord(collection, report, metadata.UpdatedAt, s)})
+	}
+
+	fmt.Printf("Statistics for collection: %s\n", collection)
+	fmt.Printf("  last scan:  %s (%s ago)\n",
+		metadata.UpdatedAt.Format(time.RFC3339), time.Since(metadata.UpdatedAt).Round(time.Second))
+	fmt.Printf("  duration:   %s\n", report.FinishedAt.Sub(report.StartedAt).Round(time.Millisecond))
+	fmt.Printf("  parsed:     %d (%d malformed lines)\n", s.Parsed, s.Malformed)
+	fmt.Printf("  reachable:  %s\n", ratio(s.Reachable, s.Parsed))
+	fmt.Printf("  handshake:  %s\n", ratio(s.Handshake, s.Parsed))
+	fmt.Printf("  working:    %s\n", ratio(s.Working, s.Parsed))
+
+	if len(s.Failures) > 0 {
+		fmt.Println("\nFailures by stage:")
+		for _, f := range s.Failures {
+			fmt.Printf("  %-10s %s\n", f.Stage, ratio(f.Count, s.Parsed))
+		}
+	}
+
+	fmt.Printf("\nConnect latency (%d samples):\n", s.Latency.Count)
+	if s.Latency.Count > 0 {
+		fmt.Printf("  p50: %s  p90: %s  p99: %s\n",
+			s.Latency.P50.Round(time.Millisecond), s.Latency.P90.Round(time.Millisecond), s.Latency.P99.Round(time.Millisecond))
+	}
+
+	fmt.Printf("\nThroughput (%d samples):\n", s.Throughput.Count)
+	if s.Throughput.Count > 0 {
+		fmt.Printf("  p50: %.1f KiB/s  p90: %.1f KiB/s  p99: %.1f KiB/s\n",
+			s.Throughput.P50/1024, s.Throughput.P90/1024, s.Throughput.P99/1024)
+	}
+
+	return nil
+}
+
+// ratio formats n out of total with its percentage.
+func ratio(n, total int) string {
+	if total == 0 {
+		return fmt.Sprintf("%d", n)
+	}
+	return fmt.Sprintf("%d (%.1f%%)", n, float64(n)*100/float64(total))
+}
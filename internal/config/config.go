@@ -1,19 +1,57 @@
 package config
 
+import (
+	"sort"
+	"time"
+)
+
 type Config struct {
 	AppName       string `toml:"app_name"`
 	SourceRepoUrl string `toml:"source_repo_url"`
 
 	ProxyCollectionList ProxyCollectionList `toml:"proxy_collection_list"`
 	Options             Options             `toml:"options"`
+	Ranking             Ranking             `toml:"ranking"`
 }
 
-type ProxyCollectionList struct {
-	Socks5 string `toml:"socks5"`
+// ProxyCollectionList maps collection names to their settings.
+type ProxyCollectionList map[string]Collection
+
+// Collection describes a named proxy list. Protocol defaults to the
+// collection name and Enabled defaults to true when omitted.
+type Collection struct {
+	Url             string        `toml:"url"`
+	Protocol        string        `toml:"protocol"`
+	Enabled         bool          `toml:"enabled"`
+	RefreshInterval time.Duration `toml:"refresh_interval"`
 }
 
 type Options struct {
-	CacheDir string `toml:"cache_dir"`
+	CacheDir         string        `toml:"cache_dir"`
+	CacheMaxBytes    int64         `toml:"cache_max_bytes"`
+	CacheMaxEntries  int           `toml:"cache_max_entries"`
+	CacheCompression string        `toml:"cache_compression"`
+	CacheBackend     string        `toml:"cache_backend"`
+	Offline          bool          `toml:"offline"`
+	StaleIfError     bool          `toml:"stale_if_error"`
+	ScanConcurrency  int           `toml:"scan_concurrency"`
+	ScanTimeout      time.Duration `toml:"scan_timeout"`
+	CheckTarget      string        `toml:"check_target"`
+
+	SpeedTestUrl     string        `toml:"speed_test_url"`
+	SpeedTestBytes   int64         `toml:"speed_test_bytes"`
+	SpeedTestTimeout time.Duration `toml:"speed_test_timeout"`
+}
+
+// Ranking controls how get-fast scores working proxies. Each weight scales
+// a component normalised to 0..1; scans older than MaxAge are ignored and
+// the success ratio is taken over the last History scans.
+type Ranking struct {
+	LatencyWeight    float64       `toml:"latency_weight"`
+	ThroughputWeight float64       `toml:"throughput_weight"`
+	SuccessWeight    float64       `toml:"success_weight"`
+	MaxAge           time.Duration `toml:"max_age"`
+	History          int           `toml:"history"`
 }
 
 type ConfigPath struct {
@@ -21,14 +59,72 @@ type ConfigPath struct {
 	SourceRepoUrl            string                   `toml:"source_repo_url"`
 	ProxyCollectionListPatch ProxyCollectionListPatch `toml:"proxy_collection_list"`
 	OptionsPatch             OptionsPatch             `toml:"options"`
+	RankingPatch             RankingPatch             `toml:"ranking"`
 }
 
-type ProxyCollectionListPatch struct {
-	Socks5 *string `toml:"socks5"`
+type ProxyCollectionListPatch map[string]CollectionPatch
+
+type CollectionPatch struct {
+	Url             *string        `toml:"url"`
+	Protocol        *string        `toml:"protocol"`
+	Enabled         *bool          `toml:"enabled"`
+	RefreshInterval *time.Duration `toml:"refresh_interval"`
 }
 
 type OptionsPatch struct {
-	CacheDir *string `toml:"cache_dir"`
+	CacheDir         *string        `toml:"cache_dir"`
+	CacheMaxBytes    *int64         `toml:"cache_max_bytes"`
+	CacheMaxEntries  *int           `toml:"cache_max_entries"`
+	CacheCompression *string        `toml:"cache_compression"`
+	CacheBackend     *string        `toml:"cache_backend"`
+	Offline          *bool          `toml:"offline"`
+	StaleIfError     *bool          `toml:"stale_if_error"`
+	ScanConcurrency  *int           `toml:"scan_concurrency"`
+	ScanTimeout      *time.Duration `toml:"scan_timeout"`
+	CheckTarget      *string        `toml:"check_target"`
+
+	SpeedTestUrl     *string        `toml:"speed_test_url"`
+	SpeedTestBytes   *int64         `toml:"speed_test_bytes"`
+	SpeedTestTimeout *time.Duration `toml:"speed_test_timeout"`
+}
+
+type RankingPatch struct {
+	LatencyWeight    *float64       `toml:"latency_weight"`
+	ThroughputWeight *float64       `toml:"throughput_weight"`
+	SuccessWeight    *float64       `toml:"success_weight"`
+	MaxAge           *time.Duration `toml:"max_age"`
+	History          *int           `toml:"history"`
+}
+
+// Names returns the collection names in alphabetical order.
+func (l ProxyCollectionList) Names() []string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply patches an existing collection or adds a new enabled one.
+func (p CollectionPatch) Apply(c Collection) Collection {
+	if p.Url != nil {
+		c.Url = *p.Url
+	}
+
+	if p.Protocol != nil {
+		c.Protocol = *p.Protocol
+	}
+
+	if p.Enabled != nil {
+		c.Enabled = *p.Enabled
+	}
+
+	if p.RefreshInterval != nil {
+		c.RefreshInterval = *p.RefreshInterval
+	}
+
+	return c
 }
 
 func (c *Config) ApplyPatch(p ConfigPath) {
@@ -40,11 +136,86 @@ func (c *Config) ApplyPatch(p ConfigPath) {
 		c.SourceRepoUrl = p.SourceRepoUrl
 	}
 
-	if p.ProxyCollectionListPatch.Socks5 != nil {
-		c.ProxyCollectionList.Socks5 = *p.ProxyCollectionListPatch.Socks5
+	if len(p.ProxyCollectionListPatch) > 0 && c.ProxyCollectionList == nil {
+		c.ProxyCollectionList = ProxyCollectionList{}
+	}
+	for name, patch := range p.ProxyCollectionListPatch {
+		collection, ok := c.ProxyCollectionList[name]
+		if !ok {
+			collection = Collection{Enabled: true}
+		}
+		c.ProxyCollectionList[name] = patch.Apply(collection)
 	}
 
 	if p.OptionsPatch.CacheDir != nil {
 		c.Options.CacheDir = *p.OptionsPatch.CacheDir
 	}
+
+	if p.OptionsPatch.CacheMaxBytes != nil {
+		c.Options.CacheMaxBytes = *p.OptionsPatch.CacheMaxBytes
+	}
+
+	if p.OptionsPatch.CacheMaxEntries != nil {
+		c.Options.CacheMaxEntries = *p.OptionsPatch.CacheMaxEntries
+	}
+
+	if p.OptionsPatch.CacheCompression != nil {
+		c.Options.CacheCompression = *p.OptionsPatch.CacheCompression
+	}
+
+	if p.OptionsPatch.CacheBackend != nil {
+		c.Options.CacheBackend = *p.OptionsPatch.CacheBackend
+	}
+
+	if p.OptionsPatch.Offline != nil {
+		c.Options.Offline = *p.OptionsPatch.Offline
+	}
+
+	if p.OptionsPatch.StaleIfError != nil {
+		c.Options.StaleIfError = *p.OptionsPatch.StaleIfError
+	}
+
+	if p.OptionsPatch.ScanConcurrency != nil {
+		c.Options.ScanConcurrency = *p.OptionsPatch.ScanConcurrency
+	}
+
+	if p.OptionsPatch.ScanTimeout != nil {
+		c.Options.ScanTimeout = *p.OptionsPatch.ScanTimeout
+	}
+
+	if p.OptionsPatch.CheckTarget != nil {
+		c.Options.CheckTarget = *p.OptionsPatch.CheckTarget
+	}
+
+	if p.OptionsPatch.SpeedTestUrl != nil {
+		c.Options.SpeedTestUrl = *p.OptionsPatch.SpeedTestUrl
+	}
+
+	if p.OptionsPatch.SpeedTestBytes != nil {
+		c.Options.SpeedTestBytes = *p.OptionsPatch.SpeedTestBytes
+	}
+
+	if p.OptionsPatch.SpeedTestTimeout != nil {
+		c.Options.SpeedTestTimeout = *p.OptionsPatch.SpeedTestTimeout
+	}
+
+	if p.RankingPatch.LatencyWeight != nil {
+		c.Ranking.LatencyWeight = *p.RankingPatch.LatencyWeight
+	}
+
+	if p.RankingPatch.ThroughputWeight != nil {
+		c.Ranking.ThroughputWeight = *p.RankingPatch.ThroughputWeight
+	}
+
+	if p.RankingPatch.SuccessWeight != nil {
+		c.Ranking.SuccessWeight = *p.RankingPatch.SuccessWeight
+	}
+
+	if p.RankingPatch.MaxAge != nil {
+		c.Ranking.MaxAge = *p.RankingPatch.MaxAge
+	}
+
+	if p.RankingPatch.History != nil {
+		c.Ranking.History = *p.RankingPatch.History
+	}
 }
@@ -2,23 +2,23 @@ package config
 
 import (
 	"errors"
-	"flag"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"free-proxy-list-speed-checker/internal/proxylist"
+
 	"github.com/BurntSushi/toml"
 )
 
-func Load() (*Config, error) {
-	configPath := flag.String("config", "config.toml", "Path to config file")
-	flag.Parse()
-
+// Load reads the config file at configPath and applies its local override.
+// A bare file name is looked up in the config directory.
+func Load(configPath string) (*Config, error) {
 	const configDir = "config"
 
-	resolvedPath := *configPath
+	resolvedPath := configPath
 	if !filepath.IsAbs(resolvedPath) && filepath.Dir(resolvedPath) == "." {
 		resolvedPath = filepath.Join(configDir, resolvedPath)
 	}
@@ -30,14 +30,22 @@ func Load() (*Config, error) {
 
 	if _, err := os.Stat(resolvedPath); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			log.Fatalf("Config file %s not found", resolvedPath)
+			return nil, fmt.Errorf("config file %s not found", resolvedPath)
 		}
-		log.Fatalf("Cannot access config file %s: %v", resolvedPath, err)
+		return nil, fmt.Errorf("cannot access config file %s: %w", resolvedPath, err)
 	}
 
 	var config Config
-	if _, err := toml.DecodeFile(resolvedPath, &config); err != nil {
-		fmt.Println(err)
+	md, err := toml.DecodeFile(resolvedPath, &config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse config file %s: %w", resolvedPath, err)
+	}
+
+	for name, collection := range config.ProxyCollectionList {
+		if !md.IsDefined("proxy_collection_list", name, "enabled") {
+			collection.Enabled = true
+		}
+		config.ProxyCollectionList[name] = collection
 	}
 
 	ext := filepath.Ext(resolvedPath)
@@ -46,12 +54,22 @@ func Load() (*Config, error) {
 	if _, err := os.Stat(localPath); err == nil {
 		var patch ConfigPath
 		if _, err := toml.DecodeFile(localPath, &patch); err != nil {
-			fmt.Println(err)
+			return nil, fmt.Errorf("cannot parse local config file %s: %w", localPath, err)
 		}
 		config.ApplyPatch(patch)
 	} else if !errors.Is(err, os.ErrNotExist) {
 		return nil, fmt.Errorf("cannot access local config file %s: %w", localPath, err)
 	}
 
+	for name, collection := range config.ProxyCollectionList {
+		if collection.Protocol == "" {
+			collection.Protocol = name
+		}
+		if !slices.Contains(proxylist.Schemes, collection.Protocol) {
+			return nil, fmt.Errorf("collection %s: unknown protocol %q", name, collection.Protocol)
+		}
+		config.ProxyCollectionList[name] = collection
+	}
+
 	return &config, nil
 }
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes config.toml and, when local is not empty,
+// config.local.toml to a temporary directory and returns the path of
+// config.toml.
+func writeConfig(t *testing.T, main, local string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte(main), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if local != "" {
+		if err := os.WriteFile(filepath.Join(dir, "config.local.toml"), []byte(local), 0644); err != nil {
+			t.Fatalf("Failed to write local config: %v", err)
+		}
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+app_name = "fpl"
+
+[proxy_collection_list.http]
+url = "https://example.com/http.txt"
+
+[proxy_collection_list.mixed]
+url = "https://example.com/mixed.txt"
+protocol = "socks5"
+enabled = false
+
+[proxy_collection_list.socks4]
+url = "https://example.com/socks4.txt"
+
+[options]
+scan_concurrency = 10
+`, `
+[proxy_collection_list.http]
+url = "https://mirror.example.com/http.txt"
+
+[proxy_collection_list.socks4]
+enabled = false
+
+[proxy_collection_list.extra]
+url = "https://example.com/extra.txt"
+protocol = "socks4a"
+refresh_interval = "1h"
+
+[options]
+scan_concurrency = 50
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	want := ProxyCollectionList{
+		// Enabled defaults to true when the main file omits it, and the
+		// local file only overrides the URL.
+		"http": {Url: "https://mirror.example.com/http.txt", Protocol: "http", Enabled: true},
+		// An explicit enabled = false is kept.
+		"mixed": {Url: "https://example.com/mixed.txt", Protocol: "socks5"},
+		// The local file disables a collection.
+		"socks4": {Url: "https://example.com/socks4.txt", Protocol: "socks4"},
+		// The local file adds a collection, enabled by default.
+		"extra": {Url: "https://example.com/extra.txt", Protocol: "socks4a", Enabled: true, RefreshInterval: time.Hour},
+	}
+	if len(cfg.ProxyCollectionList) != len(want) {
+		t.Errorf("Expected collections %v, got %v", want.Names(), cfg.ProxyCollectionList.Names())
+	}
+	for name, collection := range want {
+		if got := cfg.ProxyCollectionList[name]; got != collection {
+			t.Errorf("Collection %s: expected %+v, got %+v", name, collection, got)
+		}
+	}
+
+	if cfg.AppName != "fpl" {
+		t.Errorf("AppName: expected %q, got %q", "fpl", cfg.AppName)
+	}
+	if cfg.Options.ScanConcurrency != 50 {
+		t.Errorf("ScanConcurrency: expected 50, got %d", cfg.Options.ScanConcurrency)
+	}
+}
+
+func TestLoadDefaultsProtocolOfAddedCollection(t *testing.T) {
+	path := writeConfig(t, "", `
+[proxy_collection_list.socks5]
+url = "https://example.com/socks5.txt"
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := cfg.ProxyCollectionList["socks5"]; got.Protocol != "socks5" || !got.Enabled {
+		t.Errorf("Expected an enabled socks5 collection, got %+v", got)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		main  string
+		local string
+		want  string
+	}{
+		{
+			name: "unknown protocol",
+			main: "[proxy_collection_list.fast]\nurl = \"https://example.com/fast.txt\"\nprotocol = \"ftp\"\n",
+			want: `collection fast: unknown protocol "ftp"`,
+		},
+		{
+			name: "name is not a protocol",
+			main: "[proxy_collection_list.fast]\nurl = \"https://example.com/fast.txt\"\n",
+			want: `collection fast: unknown protocol "fast"`,
+		},
+		{
+			name:  "unknown protocol in local file",
+			main:  "[proxy_collection_list.http]\nurl = \"https://example.com/http.txt\"\n",
+			local: "[proxy_collection_list.http]\nprotocol = \"HTTP\"\n",
+			want:  `collection http: unknown protocol "HTTP"`,
+		},
+		{
+			name: "invalid main file",
+			main: "app_name = ",
+			want: "cannot parse config file",
+		},
+		{
+			name:  "invalid local file",
+			local: "[options\n",
+			want:  "cannot parse local config file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.main, tt.local))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "config.toml"))
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+// Package export renders proxies as configuration for proxy clients.
+//
+// Every output format is registered in one registry, so adding a format is
+// a matter of writing its renderer and calling register in formats.go.
+package export
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/template"
+
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+// Entry is a proxy to export. Name is unique within one export and is used
+// by formats that label their proxies.
+type Entry struct {
+	Name  string
+	Proxy proxylist.Proxy
+}
+
+// Format is a registered export format.
+type Format struct {
+	Name        string
+	Description string
+	// Supports reports whether a proxy can be expressed in the format. A
+	// nil Supports accepts every proxy.
+	Supports func(p proxylist.Proxy) bool
+	Render   func(w io.Writer, entries []Entry) error
+}
+
+var formats = map[string]Format{}
+
+func register(f Format) {
+	if _, exists := formats[f.Name]; exists {
+		panic("export: format registered twice: " + f.Name)
+	}
+	formats[f.Name] = f
+}
+
+// Lookup returns the format registered under name.
+func Lookup(name string) (Format, bool) {
+	f, ok := formats[name]
+	return f, ok
+}
+
+// Formats returns the registered formats sorted by name.
+func Formats() []Format {
+	result := make([]Format, 0, len(formats))
+	for _, f := range formats {
+		result = append(result, f)
+	}
+	slices.SortFunc(result, func(a, b Format) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return result
+}
+
+// Write renders entries in the named format. Entries the format cannot
+// express are left out and returned. If it cannot express any of them,
+// nothing is written and an error is returned.
+func Write(w io.Writer, name string, entries []Entry) ([]Entry, error) {
+	f, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown export format %q", name)
+	}
+
+	var supported, skipped []Entry
+	for _, e := range entries {
+		if f.Supports == nil || f.Supports(e.Proxy) {
+			supported = append(supported, e)
+		} else {
+			skipped = append(skipped, e)
+		}
+	}
+	if len(supported) == 0 && len(skipped) > 0 {
+		return skipped, fmt.Errorf("none of the %d proxies can be exported as %s", len(skipped), name)
+	}
+
+	if err := f.Render(w, supported); err != nil {
+		return skipped, fmt.Errorf("failed to render %s: %w", name, err)
+	}
+
+	return skipped, nil
+}
+
+// Kind returns the protocol family of a proxy scheme: http, socks4 or
+// socks5. The https scheme names HTTP proxies that allow CONNECT to port
+// 443, so it is http as well.
+func Kind(scheme string) string {
+	switch scheme {
+	case "http", "https":
+		return "http"
+	case "socks4", "socks4a":
+		return "socks4"
+	case "socks5", "socks5h":
+		return "socks5"
+	}
+	return scheme
+}
+
+// textFormat returns a renderer executing a text/template over the
+// entries. The template can use templateFuncs.
+func textFormat(text string) func(w io.Writer, entries []Entry) error {
+	tmpl := template.Must(template.New("").Funcs(templateFuncs).Parse(text))
+
+	return func(w io.Writer, entries []Entry) error {
+		return tmpl.Execute(w, entries)
+	}
+}
@@ -0,0 +1,143 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"text/template"
+
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+func init() {
+	register(Format{
+		Name:        "plain",
+		Description: "host:port, one per line",
+		Render: textFormat(`{{range .}}{{.Proxy.Address}}
+{{end}}`),
+	})
+
+	register(Format{
+		Name:        "curl",
+		Description: "curl --proxy arguments, one per line",
+		Render: textFormat(`{{range .}}--proxy {{url .Proxy}}
+{{end}}`),
+	})
+
+	register(Format{
+		Name:        "proxychains",
+		Description: "[ProxyList] section of proxychains.conf",
+		Render: textFormat(`[ProxyList]
+{{range .}}{{kind .Proxy.Scheme}}	{{.Proxy.Host}}	{{.Proxy.Port}}{{if .Proxy.Username}}	{{.Proxy.Username}}	{{.Proxy.Password}}{{end}}
+{{end}}`),
+	})
+
+	register(Format{
+		Name:        "pac",
+		Description: "Proxy auto-config file trying the proxies in order; credentials are not supported",
+		Supports:    withoutCredentials,
+		Render: textFormat(`function FindProxyForURL(url, host) {
+	return "{{range $i, $e := .}}{{if $i}}; {{end}}{{pac $e.Proxy}}{{end}}";
+}
+`),
+	})
+
+	register(Format{
+		Name:        "clash",
+		Description: "Clash proxies YAML; socks4 proxies are not supported",
+		Supports:    func(p proxylist.Proxy) bool { return Kind(p.Scheme) != "socks4" },
+		Render: textFormat(`{{if not .}}proxies: []
+{{else}}proxies:
+{{range .}}  - name: {{quote .Name}}
+    type: {{kind .Proxy.Scheme}}
+    server: {{quote .Proxy.Host}}
+    port: {{.Proxy.Port}}
+{{- if .Proxy.Username}}
+    username: {{quote .Proxy.Username}}
+    password: {{quote .Proxy.Password}}
+{{- end}}
+{{end}}{{end}}`),
+	})
+
+	register(Format{
+		Name:        "sing-box",
+		Description: "sing-box outbounds JSON",
+		Render:      renderSingBox,
+	})
+}
+
+// templateFuncs are available to the templates of textFormat.
+var templateFuncs = template.FuncMap{
+	"kind":  Kind,
+	"url":   ClientURL,
+	"pac":   pacEntry,
+	"quote": quote,
+}
+
+func withoutCredentials(p proxylist.Proxy) bool {
+	return p.Username == ""
+}
+
+// ClientURL returns the proxy URL as proxy clients understand it: an https
+// proxy is a plain HTTP proxy, not one reached over TLS.
+func ClientURL(p proxylist.Proxy) string {
+	if p.Scheme == "https" {
+		p.Scheme = "http"
+	}
+	return p.String()
+}
+
+// pacEntry returns the PAC directive using the proxy.
+func pacEntry(p proxylist.Proxy) string {
+	switch Kind(p.Scheme) {
+	case "socks4":
+		return "SOCKS " + p.Address()
+	case "socks5":
+		return "SOCKS5 " + p.Address()
+	}
+	return "PROXY " + p.Address()
+}
+
+// quote returns s as a double-quoted string, which is valid JSON and YAML.
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+type singBoxOutbound struct {
+	Type       string `json:"type"`
+	Tag        string `json:"tag"`
+	Server     string `json:"server"`
+	ServerPort int    `json:"server_port"`
+	Version    string `json:"version,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password,omitempty"`
+}
+
+func renderSingBox(w io.Writer, entries []Entry) error {
+	outbounds := make([]singBoxOutbound, len(entries))
+	for i, e := range entries {
+		outbound := singBoxOutbound{
+			Type:       "socks",
+			Tag:        e.Name,
+			Server:     e.Proxy.Host,
+			ServerPort: e.Proxy.Port,
+			Username:   e.Proxy.Username,
+			Password:   e.Proxy.Password,
+		}
+		switch e.Proxy.Scheme {
+		case "socks4":
+			outbound.Version = "4"
+		case "socks4a":
+			outbound.Version = "4a"
+		case "socks5", "socks5h":
+			outbound.Version = "5"
+		default:
+			outbound.Type = "http"
+		}
+		outbounds[i] = outbound
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]any{"outbounds": outbounds})
+}
@@ -0,0 +1,291 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/network"
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+func upstreams(ports ...int) []Upstream {
+	var result []Upstream
+	for i, port := range ports {
+		result = append(result, Upstream{
+			Proxy:   proxylist.Proxy{Scheme: "socks5", Host: "10.0.0.1", Port: port},
+			Latency: time.Duration(len(ports)-i) * time.Millisecond,
+		})
+	}
+	return result
+}
+
+func pickPorts(p *pool, target string, n int) []int {
+	var ports []int
+	for range n {
+		ports = append(ports, p.pick(target, nil).Proxy.Port)
+	}
+	return ports
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPoolStrategies(t *testing.T) {
+	p := newPool(upstreams(1, 2, 3), RoundRobin, time.Minute)
+	if got := pickPorts(p, "a:80", 4); !equal(got, []int{1, 2, 3, 1}) {
+		t.Errorf("Round-robin: expected [1 2 3 1], got %v", got)
+	}
+
+	p = newPool(upstreams(1, 2, 3), LeastLatency, time.Minute)
+	if got := pickPorts(p, "a:80", 2); !equal(got, []int{3, 3}) {
+		t.Errorf("Least-latency: expected [3 3], got %v", got)
+	}
+
+	p = newPool(upstreams(1, 2, 3), Sticky, time.Minute)
+	a := pickPorts(p, "a:80", 1)[0]
+	b := pickPorts(p, "b:443", 1)[0]
+	if a == b {
+		t.Errorf("Sticky: expected different destinations to be spread, both got %d", a)
+	}
+	if got := pickPorts(p, "a:443", 3); !equal(got, []int{a, a, a}) {
+		t.Errorf("Sticky: expected destination a to stay on %d, got %v", a, got)
+	}
+}
+
+func TestPoolBoundsStickyDestinations(t *testing.T) {
+	p := newPool(upstreams(1, 2, 3), Sticky, time.Minute)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	for i := range maxSticky + 10 {
+		p.pick("host"+strconv.Itoa(i)+":80", nil)
+		now = now.Add(time.Millisecond)
+	}
+	if len(p.sticky) != maxSticky {
+		t.Errorf("Expected %d sticky destinations, got %d", maxSticky, len(p.sticky))
+	}
+	if _, ok := p.sticky["host0"]; ok {
+		t.Error("Expected the least recently used destination to be forgotten")
+	}
+
+	now = now.Add(stickyIdle)
+	p.pick("new:80", nil)
+	if len(p.sticky) != 1 {
+		t.Errorf("Expected idle destinations to be forgotten, %d left", len(p.sticky))
+	}
+}
+
+func TestPoolSkipsFailedUpstreams(t *testing.T) {
+	p := newPool(upstreams(1, 2), RoundRobin, time.Minute)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	p.fail(p.upstreams[0])
+	if got := pickPorts(p, "a:80", 2); !equal(got, []int{2, 2}) {
+		t.Errorf("Expected the failed upstream to be skipped, got %v", got)
+	}
+
+	p.fail(p.upstreams[1])
+	if u := p.pick("a:80", nil); u == nil {
+		t.Error("Expected an upstream when all of them are down")
+	}
+
+	now = now.Add(2 * time.Minute)
+	p.succeed(p.upstreams[1])
+	if got := pickPorts(p, "a:80", 2); equal(got, []int{2, 2}) {
+		t.Errorf("Expected the upstream to come back after the cooldown, got %v", got)
+	}
+
+	tried := map[*upstream]bool{p.upstreams[0]: true, p.upstreams[1]: true}
+	if u := p.pick("a:80", tried); u != nil {
+		t.Errorf("Expected no upstream once all were tried, got %v", u.Proxy)
+	}
+}
+
+// listen starts a server on a random local port, handing every connection
+// to handle.
+func listen(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func echo(conn net.Conn) {
+	io.Copy(conn, conn)
+}
+
+// startGateway serves a gateway whose upstreams are simulated by dial.
+func startGateway(t *testing.T, ups []Upstream, dial DialFunc) proxylist.Proxy {
+	t.Helper()
+
+	s, err := New(ups, Options{Timeout: 2 * time.Second, Dial: dial})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx, l) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve returned %v", err)
+		}
+	})
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return proxylist.Proxy{Host: host, Port: portNumber}
+}
+
+// roundTrip connects to target through the gateway and checks that msg is
+// echoed back.
+func roundTrip(t *testing.T, gateway proxylist.Proxy, scheme, target, msg string) {
+	t.Helper()
+
+	gateway.Scheme = scheme
+	conn, err := network.DialThrough(context.Background(), gateway, target, 2*time.Second)
+	if err != nil {
+		t.Fatalf("%s: dial through gateway failed: %v", scheme, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		t.Fatalf("%s: write failed: %v", scheme, err)
+	}
+	got := make([]byte, len(msg))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("%s: read failed: %v", scheme, err)
+	}
+	if string(got) != msg {
+		t.Errorf("%s: expected %q, got %q", scheme, msg, got)
+	}
+}
+
+// direct simulates working upstreams by dialing the target directly.
+func direct(ctx context.Context, proxy proxylist.Proxy, target string, timeout time.Duration) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", target)
+}
+
+func TestServeSOCKS5AndHTTPConnect(t *testing.T) {
+	target := listen(t, echo)
+	gateway := startGateway(t, upstreams(1), direct)
+
+	roundTrip(t, gateway, "socks5", target, "hello over socks5")
+	roundTrip(t, gateway, "http", target, "hello over connect")
+}
+
+func TestServeReportsUnreachableTarget(t *testing.T) {
+	gateway := startGateway(t, upstreams(1, 2), func(ctx context.Context, proxy proxylist.Proxy, target string, timeout time.Duration) (net.Conn, error) {
+		return nil, errors.New("proxy down")
+	})
+
+	for _, scheme := range []string{"socks5", "http"} {
+		gateway.Scheme = scheme
+		if conn, err := network.DialThrough(context.Background(), gateway, "127.0.0.1:9", time.Second); err == nil {
+			conn.Close()
+			t.Errorf("%s: expected an error when no upstream works", scheme)
+		}
+	}
+}
+
+func TestFailover(t *testing.T) {
+	target := listen(t, echo)
+	// The upstream on port 1 accepts the tunnel, then dies as soon as the
+	// client sends data.
+	dying := listen(t, func(conn net.Conn) {
+		conn.Read(make([]byte, 1))
+	})
+
+	var dialed []int
+	dial := func(ctx context.Context, proxy proxylist.Proxy, target string, timeout time.Duration) (net.Conn, error) {
+		dialed = append(dialed, proxy.Port)
+		switch proxy.Port {
+		case 1:
+			return net.Dial("tcp", dying)
+		case 2:
+			return nil, errors.New("proxy down")
+		}
+		return direct(ctx, proxy, target, timeout)
+	}
+
+	gateway := startGateway(t, upstreams(1, 2, 3), dial)
+	roundTrip(t, gateway, "socks5", target, "replayed after failover")
+
+	if !equal(dialed, []int{1, 2, 3}) {
+		t.Errorf("Expected upstreams 1, 2 and 3 to be tried, got %v", dialed)
+	}
+}
+
+func TestCleanCloseDoesNotFailOver(t *testing.T) {
+	const msg = "nothing to say"
+	// The destination reads the request and closes without answering.
+	silent := listen(t, func(conn net.Conn) {
+		io.ReadFull(conn, make([]byte, len(msg)))
+	})
+
+	var dialed []int
+	dial := func(ctx context.Context, proxy proxylist.Proxy, target string, timeout time.Duration) (net.Conn, error) {
+		dialed = append(dialed, proxy.Port)
+		return net.Dial("tcp", silent)
+	}
+
+	gateway := startGateway(t, upstreams(1, 2), dial)
+	gateway.Scheme = "socks5"
+	conn, err := network.DialThrough(context.Background(), gateway, "127.0.0.1:9", 2*time.Second)
+	if err != nil {
+		t.Fatalf("dial through gateway failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if data, err := io.ReadAll(conn); err != nil || len(data) != 0 {
+		t.Errorf("Expected a clean close without data, got %q, %v", data, err)
+	}
+
+	if !equal(dialed, []int{1}) {
+		t.Errorf("Expected only upstream 1 to be dialed, got %v", dialed)
+	}
+}
@@ -0,0 +1,201 @@
+package gateway
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+// Strategy selects the upstream of a new connection.
+type Strategy string
+
+const (
+	// RoundRobin cycles through the upstreams.
+	RoundRobin Strategy = "round-robin"
+	// LeastLatency picks the upstream with the lowest latency measured by
+	// the scan.
+	LeastLatency Strategy = "least-latency"
+	// Sticky keeps using the same upstream for a destination host while it
+	// works, and assigns the next one round-robin when it does not.
+	Sticky Strategy = "sticky"
+)
+
+// Strategies lists the supported strategies.
+var Strategies = []Strategy{RoundRobin, LeastLatency, Sticky}
+
+// ParseStrategy converts a strategy name into a Strategy. An empty name
+// selects RoundRobin.
+func ParseStrategy(s string) (Strategy, error) {
+	if s == "" {
+		return RoundRobin, nil
+	}
+	for _, strategy := range Strategies {
+		if Strategy(s) == strategy {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown strategy %q", s)
+}
+
+// Upstream is a proxy connections are forwarded through.
+type Upstream struct {
+	Proxy   proxylist.Proxy
+	Latency time.Duration
+}
+
+// Sticky assignments idle for stickyIdle are forgotten, and at most
+// maxSticky destinations are remembered; the least recently used one makes
+// room for a new one.
+const (
+	stickyIdle = 30 * time.Minute
+	maxSticky  = 4096
+)
+
+// upstream is the state the pool keeps about an Upstream.
+type upstream struct {
+	Upstream
+	downUntil time.Time
+}
+
+// pool selects upstreams and tracks which of them are failing. An upstream
+// that fails is skipped for the cooldown, unless every upstream is down.
+type pool struct {
+	mu        sync.Mutex
+	upstreams []*upstream
+	strategy  Strategy
+	cooldown  time.Duration
+	next      int
+	sticky    map[string]stickyEntry
+	now       func() time.Time
+}
+
+// stickyEntry is the upstream assigned to a destination host.
+type stickyEntry struct {
+	upstream *upstream
+	usedAt   time.Time
+}
+
+func newPool(upstreams []Upstream, strategy Strategy, cooldown time.Duration) *pool {
+	p := &pool{
+		strategy: strategy,
+		cooldown: cooldown,
+		sticky:   map[string]stickyEntry{},
+		now:      time.Now,
+	}
+	for _, u := range upstreams {
+		p.upstreams = append(p.upstreams, &upstream{Upstream: u})
+	}
+	return p
+}
+
+// pick returns the upstream to use for target, skipping the ones in tried.
+// It returns nil once every upstream has been tried.
+func (p *pool) pick(target string, tried map[*upstream]bool) *upstream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	var candidates []*upstream
+	for _, u := range p.upstreams {
+		if !tried[u] && !now.Before(u.downUntil) {
+			candidates = append(candidates, u)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, u := range p.upstreams {
+			if !tried[u] {
+				candidates = append(candidates, u)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	switch p.strategy {
+	case LeastLatency:
+		best := candidates[0]
+		for _, u := range candidates[1:] {
+			if u.Latency < best.Latency {
+				best = u
+			}
+		}
+		return best
+
+	case Sticky:
+		host := destinationHost(target)
+		if e, ok := p.sticky[host]; ok && now.Sub(e.usedAt) < stickyIdle && slices.Contains(candidates, e.upstream) {
+			p.sticky[host] = stickyEntry{upstream: e.upstream, usedAt: now}
+			return e.upstream
+		}
+		u := p.roundRobin(candidates)
+		if _, ok := p.sticky[host]; !ok && len(p.sticky) >= maxSticky {
+			p.pruneSticky(now)
+		}
+		p.sticky[host] = stickyEntry{upstream: u, usedAt: now}
+		return u
+	}
+
+	return p.roundRobin(candidates)
+}
+
+// roundRobin returns the first candidate at or after the cursor and moves
+// the cursor past it. The caller must hold the lock.
+func (p *pool) roundRobin(candidates []*upstream) *upstream {
+	for range p.upstreams {
+		u := p.upstreams[p.next%len(p.upstreams)]
+		p.next++
+		if slices.Contains(candidates, u) {
+			return u
+		}
+	}
+	return candidates[0]
+}
+
+// pruneSticky forgets the idle sticky assignments, or the least recently
+// used one if none is idle. The caller must hold the lock.
+func (p *pool) pruneSticky(now time.Time) {
+	var oldest string
+	for host, e := range p.sticky {
+		if now.Sub(e.usedAt) >= stickyIdle {
+			delete(p.sticky, host)
+			continue
+		}
+		if oldest == "" || e.usedAt.Before(p.sticky[oldest].usedAt) {
+			oldest = host
+		}
+	}
+	if len(p.sticky) >= maxSticky {
+		delete(p.sticky, oldest)
+	}
+}
+
+// succeed records a working connection through u.
+func (p *pool) succeed(u *upstream) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u.downUntil = time.Time{}
+}
+
+// fail marks u as down for the cooldown.
+func (p *pool) fail(u *upstream) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u.downUntil = p.now().Add(p.cooldown)
+}
+
+// destinationHost returns the host of a host:port target, so that all
+// ports of a destination share a sticky upstream.
+func destinationHost(target string) string {
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		return target
+	}
+	return host
+}
@@ -0,0 +1,308 @@
+// Package gateway runs a local proxy that forwards every connection through
+// one of a set of upstream proxies.
+//
+// A single listener accepts both SOCKS5 (RFC 1928, CONNECT without
+// authentication) and HTTP CONNECT clients; the protocol is detected from
+// the first byte. Connections are tunnelled through an upstream chosen by
+// the configured Strategy. When an upstream cannot be reached, or dies
+// before the destination has sent any data, the connection fails over to
+// the next upstream and the client data sent so far is replayed.
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/network"
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+const (
+	defaultTimeout  = 10 * time.Second
+	defaultAttempts = 3
+	defaultCooldown = time.Minute
+)
+
+// DialFunc opens a connection to target through proxy.
+type DialFunc func(ctx context.Context, proxy proxylist.Proxy, target string, timeout time.Duration) (net.Conn, error)
+
+// Options controls a Server. Zero values select the defaults.
+type Options struct {
+	Strategy Strategy
+	// Timeout bounds the client handshake and each upstream handshake.
+	Timeout time.Duration
+	// Attempts is the number of upstreams tried for one connection.
+	Attempts int
+	// Cooldown is how long a failed upstream is skipped.
+	Cooldown time.Duration
+	// Dial defaults to network.DialThrough.
+	Dial DialFunc
+}
+
+// Server forwards client connections through upstream proxies.
+type Server struct {
+	pool     *pool
+	timeout  time.Duration
+	attempts int
+	dial     DialFunc
+}
+
+// New creates a server forwarding through upstreams.
+func New(upstreams []Upstream, opts Options) (*Server, error) {
+	if len(upstreams) == 0 {
+		return nil, errors.New("no upstream proxies")
+	}
+
+	strategy, err := ParseStrategy(string(opts.Strategy))
+	if err != nil {
+		return nil, err
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.Attempts <= 0 {
+		opts.Attempts = defaultAttempts
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = defaultCooldown
+	}
+	if opts.Dial == nil {
+		opts.Dial = network.DialThrough
+	}
+
+	return &Server{
+		pool:     newPool(upstreams, strategy, opts.Cooldown),
+		timeout:  opts.Timeout,
+		attempts: opts.Attempts,
+		dial:     opts.Dial,
+	}, nil
+}
+
+// Serve accepts connections on l until ctx is done, then closes l and
+// returns nil. Connections in progress are not waited for.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	stop := context.AfterFunc(ctx, func() {
+		l.Close()
+	})
+	defer stop()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		go s.handle(ctx, conn)
+	}
+}
+
+// handle detects the protocol of a client and serves it.
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return
+	}
+
+	r := bufio.NewReader(conn)
+	first, err := r.Peek(1)
+	if err != nil {
+		return
+	}
+
+	client := &bufferedConn{Conn: conn, r: r}
+	if first[0] == socks5Version {
+		err = s.serveSOCKS5(ctx, client)
+	} else {
+		err = s.serveHTTP(ctx, client)
+	}
+	if err != nil {
+		log.Printf("gateway: %s: %v", conn.RemoteAddr(), err)
+	}
+}
+
+// bufferedConn reads through the reader used to detect the protocol.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+const (
+	socks5Version      = 0x05
+	socks5NoAuth       = 0x00
+	socks5NoAcceptable = 0xFF
+	socks5CmdConnect   = 0x01
+	socks5AtypIPv4     = 0x01
+	socks5AtypDomain   = 0x03
+	socks5AtypIPv6     = 0x04
+
+	socks5Succeeded        = 0x00
+	socks5HostUnreachable  = 0x04
+	socks5CmdNotSupported  = 0x07
+	socks5AtypNotSupported = 0x08
+)
+
+// serveSOCKS5 runs the server side of an RFC 1928 CONNECT. Only the
+// no-authentication method is offered: the gateway is meant to listen on
+// a local address.
+func (s *Server) serveSOCKS5(ctx context.Context, client net.Conn) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(client, header); err != nil {
+		return fmt.Errorf("socks5 greeting: %w", err)
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(client, methods); err != nil {
+		return fmt.Errorf("socks5 greeting: %w", err)
+	}
+
+	method := byte(socks5NoAcceptable)
+	for _, m := range methods {
+		if m == socks5NoAuth {
+			method = socks5NoAuth
+		}
+	}
+	if _, err := client.Write([]byte{socks5Version, method}); err != nil {
+		return err
+	}
+	if method == socks5NoAcceptable {
+		return errors.New("socks5 client does not offer the no-authentication method")
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(client, request); err != nil {
+		return fmt.Errorf("socks5 request: %w", err)
+	}
+	if request[0] != socks5Version {
+		return fmt.Errorf("socks5 request: unexpected version %#x", request[0])
+	}
+	if request[1] != socks5CmdConnect {
+		socks5Reply(client, socks5CmdNotSupported)
+		return fmt.Errorf("socks5 command %#x not supported", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socks5AtypIPv4, socks5AtypIPv6:
+		size := net.IPv4len
+		if request[3] == socks5AtypIPv6 {
+			size = net.IPv6len
+		}
+		ip := make(net.IP, size)
+		if _, err := io.ReadFull(client, ip); err != nil {
+			return fmt.Errorf("socks5 request: %w", err)
+		}
+		host = ip.String()
+	case socks5AtypDomain:
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(client, size); err != nil {
+			return fmt.Errorf("socks5 request: %w", err)
+		}
+		domain := make([]byte, size[0])
+		if _, err := io.ReadFull(client, domain); err != nil {
+			return fmt.Errorf("socks5 request: %w", err)
+		}
+		host = string(domain)
+	default:
+		socks5Reply(client, socks5AtypNotSupported)
+		return fmt.Errorf("socks5 address type %#x not supported", request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(client, port); err != nil {
+		return fmt.Errorf("socks5 request: %w", err)
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1])))
+
+	return s.forward(ctx, client, target, func(err error) error {
+		if err != nil {
+			return socks5Reply(client, socks5HostUnreachable)
+		}
+		return socks5Reply(client, socks5Succeeded)
+	})
+}
+
+// socks5Reply sends a reply with an unspecified bound address.
+func socks5Reply(client net.Conn, code byte) error {
+	_, err := client.Write([]byte{socks5Version, code, 0x00, socks5AtypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+// serveHTTP runs the server side of an HTTP CONNECT. Other methods are
+// refused: the gateway only tunnels.
+func (s *Server) serveHTTP(ctx context.Context, client *bufferedConn) error {
+	req, err := http.ReadRequest(client.r)
+	if err != nil {
+		return fmt.Errorf("http request: %w", err)
+	}
+
+	if req.Method != http.MethodConnect {
+		httpReply(client, http.StatusMethodNotAllowed)
+		return fmt.Errorf("http method %s not supported", req.Method)
+	}
+
+	target := req.Host
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		httpReply(client, http.StatusBadRequest)
+		return fmt.Errorf("invalid CONNECT target %q", target)
+	}
+
+	return s.forward(ctx, client, target, func(err error) error {
+		if err != nil {
+			return httpReply(client, http.StatusBadGateway)
+		}
+		return httpReply(client, http.StatusOK)
+	})
+}
+
+func httpReply(client net.Conn, status int) error {
+	_, err := fmt.Fprintf(client, "HTTP/1.1 %d %s\r\n\r\n", status, http.StatusText(status))
+	return err
+}
+
+// forward tunnels client to target. reply is called once the first
+// upstream is connected, or with the error if none could be, to answer
+// the client in its protocol.
+func (s *Server) forward(ctx context.Context, client net.Conn, target string, reply func(err error) error) error {
+	t := &tunnel{
+		server: s,
+		ctx:    ctx,
+		client: client,
+		target: target,
+		tried:  map[*upstream]bool{},
+	}
+
+	if err := t.connect(); err != nil {
+		reply(err)
+		return err
+	}
+	if err := reply(nil); err != nil {
+		t.upstream.Close()
+		return err
+	}
+	if err := client.SetDeadline(time.Time{}); err != nil {
+		t.upstream.Close()
+		return err
+	}
+
+	return t.relay()
+}
@@ -0,0 +1,191 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// replayLimit caps the client data kept for replay on failover. Once the
+// client has sent more before the destination answered, a dying upstream
+// ends the connection instead.
+const replayLimit = 64 << 10
+
+// tunnel relays one client connection through an upstream, switching to
+// the next upstream if the current one fails with an error before the
+// destination has sent any data. An upstream that closes cleanly is not
+// replaced: the destination may have nothing to say.
+type tunnel struct {
+	server *Server
+	ctx    context.Context
+	client net.Conn
+	target string
+	tried  map[*upstream]bool
+
+	// responded is set once data from the destination reached the client.
+	responded atomic.Bool
+
+	// mu guards the fields below. The upstream connection is only replaced
+	// by relay, while holding mu, so relay reads it without the lock.
+	mu         sync.Mutex
+	upstream   net.Conn
+	current    *upstream
+	sent       []byte
+	overflow   bool
+	clientDone bool
+	clientGone bool
+}
+
+// connect dials the target through the next untried upstream until one
+// succeeds or the attempts are used up.
+func (t *tunnel) connect() error {
+	var lastErr error
+	for len(t.tried) < t.server.attempts {
+		u := t.server.pool.pick(t.target, t.tried)
+		if u == nil {
+			break
+		}
+		t.tried[u] = true
+
+		conn, err := t.server.dial(t.ctx, u.Proxy, t.target, t.server.timeout)
+		if err != nil {
+			log.Printf("gateway: upstream %s cannot reach %s: %v", u.Proxy, t.target, err)
+			t.server.pool.fail(u)
+			lastErr = err
+			continue
+		}
+
+		t.server.pool.succeed(u)
+		t.upstream, t.current = conn, u
+		return nil
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no upstream left to try")
+	}
+	return fmt.Errorf("no upstream could reach %s: %w", t.target, lastErr)
+}
+
+// relay copies data in both directions until either side is done.
+func (t *tunnel) relay() error {
+	go t.pump()
+
+	var err error
+	buf := make([]byte, 32<<10)
+	for {
+		n, readErr := t.upstream.Read(buf)
+		if n > 0 {
+			t.responded.Store(true)
+			if _, writeErr := t.client.Write(buf[:n]); writeErr != nil {
+				break
+			}
+		}
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				break
+			}
+			if t.failover(readErr) {
+				continue
+			}
+			if !t.responded.Load() {
+				err = fmt.Errorf("upstream %s failed before %s answered: %w", t.current.Proxy, t.target, readErr)
+			}
+			break
+		}
+	}
+
+	t.client.Close()
+	t.mu.Lock()
+	t.upstream.Close()
+	t.mu.Unlock()
+
+	return err
+}
+
+// pump copies client data to the current upstream, keeping a copy for
+// replay until the destination answers. The lock is not held while
+// writing: data recorded before a failover is replayed to the new upstream,
+// and the write to the old one fails harmlessly.
+func (t *tunnel) pump() {
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := t.client.Read(buf)
+		if n > 0 {
+			t.mu.Lock()
+			switch {
+			case t.responded.Load():
+				t.sent = nil
+			case len(t.sent)+n > replayLimit:
+				t.sent, t.overflow = nil, true
+			case !t.overflow:
+				t.sent = append(t.sent, buf[:n]...)
+			}
+			upstream := t.upstream
+			t.mu.Unlock()
+
+			// A failed write is handled by relay, which sees the broken
+			// upstream on its read side.
+			upstream.Write(buf[:n])
+		}
+		if err != nil {
+			t.mu.Lock()
+			if errors.Is(err, io.EOF) {
+				t.clientDone = true
+				closeWrite(t.upstream)
+			} else {
+				t.clientGone = true
+				t.upstream.Close()
+			}
+			t.mu.Unlock()
+			return
+		}
+	}
+}
+
+// failover replaces an upstream that failed with cause and replays the
+// client data. It reports whether the tunnel can go on.
+func (t *tunnel) failover(cause error) bool {
+	if t.responded.Load() {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.overflow || t.clientGone {
+		return false
+	}
+
+	log.Printf("gateway: upstream %s failed before %s answered: %v; trying the next one", t.current.Proxy, t.target, cause)
+	t.server.pool.fail(t.current)
+	t.upstream.Close()
+
+	if err := t.connect(); err != nil {
+		log.Printf("gateway: %v", err)
+		return false
+	}
+
+	if len(t.sent) > 0 {
+		if _, err := t.upstream.Write(t.sent); err != nil {
+			return false
+		}
+	}
+	if t.clientDone {
+		closeWrite(t.upstream)
+	}
+
+	return true
+}
+
+// closeWrite half-closes conn if it supports it, telling the destination
+// that the client has finished sending.
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+}
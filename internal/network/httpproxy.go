@@ -0,0 +1,132 @@
+package network
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+// Mode is a way of using an HTTP proxy.
+type Mode string
+
+const (
+	// ModeGet forwards plain HTTP requests sent with an absolute URI.
+	ModeGet Mode = "get"
+	// ModeConnect opens a raw tunnel with the CONNECT method.
+	ModeConnect Mode = "connect"
+)
+
+const userAgent = "free-proxy-list-speed-checker"
+
+// httpGet sends an absolute-URI GET for the root of target through an HTTP
+// proxy and expects a non-error response.
+func httpGet(conn net.Conn, proxy proxylist.Proxy, target string, timings Timings) error {
+	req, err := http.NewRequest(http.MethodGet, "http://"+target+"/", nil)
+	if err != nil {
+		return &StageError{Stage: StageRequest, Err: err}
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Close = true
+	setProxyAuth(req.Header, proxy)
+
+	start := time.Now()
+	if err := req.WriteProxy(conn); err != nil {
+		return &StageError{Stage: StageGreeting, Err: err}
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return &StageError{Stage: StageGreeting, Err: fmt.Errorf("not an HTTP proxy: %w", err)}
+	}
+	resp.Body.Close()
+
+	if err := checkProxyStatus(resp, StageRequest); err != nil {
+		return err
+	}
+	timings[StageRequest] = time.Since(start)
+
+	return nil
+}
+
+// httpConnectHandshake asks an HTTP proxy to open a tunnel to target with
+// the CONNECT method. Data the destination sent right behind the response
+// headers, such as an SSH banner, is kept for the returned connection.
+func httpConnectHandshake(conn net.Conn, proxy proxylist.Proxy, target string, timings Timings) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: http.Header{},
+	}
+	req.Header.Set("User-Agent", userAgent)
+	setProxyAuth(req.Header, proxy)
+
+	start := time.Now()
+	if err := req.Write(conn); err != nil {
+		return nil, &StageError{Stage: StageGreeting, Err: err}
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, &StageError{Stage: StageGreeting, Err: fmt.Errorf("not an HTTP proxy: %w", err)}
+	}
+
+	if err := checkProxyStatus(resp, StageConnect); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	timings[StageConnect] = time.Since(start)
+
+	if r.Buffered() == 0 {
+		return conn, nil
+	}
+	return &bufferedConn{Conn: conn, r: r}, nil
+}
+
+// bufferedConn reads the data buffered while parsing a proxy response
+// before reading from the connection itself.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// CloseWrite half-closes the underlying connection if it supports it.
+func (c *bufferedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// checkProxyStatus maps an HTTP proxy response to a stage error: 407 is an
+// authentication failure, any other status but 2xx fails at stage. A
+// redirect, such as a captive portal answering every request, is not a
+// working proxy.
+func checkProxyStatus(resp *http.Response, stage Stage) error {
+	switch {
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		return &StageError{Stage: StageAuth, Err: fmt.Errorf("proxy authentication required")}
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return &StageError{Stage: stage, Err: fmt.Errorf("proxy responded %s", resp.Status)}
+	}
+	return nil
+}
+
+func setProxyAuth(h http.Header, proxy proxylist.Proxy) {
+	if proxy.Username == "" {
+		return
+	}
+	credentials := proxy.Username + ":" + proxy.Password
+	h.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+}
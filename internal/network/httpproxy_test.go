@@ -0,0 +1,207 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+// fakeHTTPProxy is an in-process HTTP proxy that forwards absolute-URI
+// requests and tunnels CONNECT requests unless told to refuse them. With
+// redirect set it answers everything like a captive portal.
+type fakeHTTPProxy struct {
+	refuseConnect bool
+	redirect      bool
+	username      string
+}
+
+func (f *fakeHTTPProxy) start(t *testing.T, scheme string) proxylist.Proxy {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	proxy, err := proxylist.ParseLine(scheme + "://" + srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to parse proxy address: %v", err)
+	}
+	return proxy
+}
+
+func (f *fakeHTTPProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.username != "" {
+		user, _, ok := parseProxyAuth(r.Header.Get("Proxy-Authorization"))
+		if !ok || user != f.username {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+	}
+
+	if f.redirect {
+		http.Redirect(w, r, "http://portal.example/login", http.StatusFound)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		if f.refuseConnect {
+			http.Error(w, "CONNECT not allowed", http.StatusForbidden)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+
+		w.WriteHeader(http.StatusOK)
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		go io.Copy(upstream, buf)
+		io.Copy(conn, upstream)
+		return
+	}
+
+	r.RequestURI = ""
+	r.Header.Del("Proxy-Authorization")
+	resp, err := http.DefaultTransport.RoundTrip(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func parseProxyAuth(header string) (string, string, bool) {
+	r := &http.Request{Header: http.Header{"Authorization": {header}}}
+	return r.BasicAuth()
+}
+
+func TestHTTPProbe(t *testing.T) {
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(web.Close)
+
+	tlsWeb := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(tlsWeb.Close)
+	roots := x509.NewCertPool()
+	roots.AddCert(tlsWeb.Certificate())
+
+	opts := probeOptions{
+		Target:    web.Listener.Addr().String(),
+		TLSTarget: tlsWeb.Listener.Addr().String(),
+		Timeout:   time.Second,
+		TLSConfig: &tls.Config{RootCAs: roots},
+	}
+
+	tests := []struct {
+		name     string
+		scheme   string
+		server   fakeHTTPProxy
+		username string
+		ok       bool
+		modes    []Mode
+		stage    Stage
+	}{
+		{name: "http full", scheme: "http", ok: true, modes: []Mode{ModeGet, ModeConnect}},
+		{name: "https full", scheme: "https", ok: true, modes: []Mode{ModeGet, ModeConnect}},
+		{name: "http get only", scheme: "http", server: fakeHTTPProxy{refuseConnect: true}, ok: true, modes: []Mode{ModeGet}},
+		{name: "https get only", scheme: "https", server: fakeHTTPProxy{refuseConnect: true}, modes: []Mode{ModeGet}, stage: StageConnect},
+		{name: "auth required", scheme: "http", server: fakeHTTPProxy{username: "u"}, stage: StageAuth},
+		{name: "auth provided", scheme: "http", server: fakeHTTPProxy{username: "u"}, username: "u", ok: true, modes: []Mode{ModeGet, ModeConnect}},
+		{name: "http captive portal", scheme: "http", server: fakeHTTPProxy{redirect: true}, stage: StageRequest},
+		{name: "https captive portal", scheme: "https", server: fakeHTTPProxy{redirect: true}, stage: StageConnect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := tt.server.start(t, tt.scheme)
+			proxy.Username = tt.username
+
+			r := probe(context.Background(), proxy, opts)
+			if !r.Handshake {
+				t.Fatalf("Expected handshake, got error %q", r.Error)
+			}
+			if r.OK != tt.ok {
+				t.Errorf("OK: expected %v, got %v (%s)", tt.ok, r.OK, r.Error)
+			}
+			if !slices.Equal(r.Modes, tt.modes) {
+				t.Errorf("Modes: expected %v, got %v", tt.modes, r.Modes)
+			}
+			if r.FailedStage != tt.stage {
+				t.Errorf("FailedStage: expected %q, got %q", tt.stage, r.FailedStage)
+			}
+		})
+	}
+}
+
+// startConnectProxy starts a proxy that answers the first CONNECT request
+// with response in a single write.
+func startConnectProxy(t *testing.T, response string) proxylist.Proxy {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, err := http.ReadRequest(bufio.NewReader(conn)); err != nil {
+			return
+		}
+		conn.Write([]byte(response))
+	}()
+
+	return listenerProxy(t, l, "http")
+}
+
+func TestDialThroughKeepsDataAfterConnectResponse(t *testing.T) {
+	// The response and the destination's banner arrive together, as from
+	// a server that speaks first.
+	proxy := startConnectProxy(t, "HTTP/1.1 200 Connection established\r\n\r\nSSH-2.0-fake\r\n")
+	conn, err := DialThrough(context.Background(), proxy, "example.com:22", time.Second)
+	if err != nil {
+		t.Fatalf("DialThrough failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	banner, err := io.ReadAll(conn)
+	if err != nil || string(banner) != "SSH-2.0-fake\r\n" {
+		t.Errorf("Expected the banner, got %q, %v", banner, err)
+	}
+
+	// A captive portal redirecting the CONNECT is not a tunnel, and its
+	// page must not be handed out as tunnel data.
+	proxy = startConnectProxy(t, "HTTP/1.1 302 Found\r\nLocation: http://portal.example/\r\nContent-Length: 6\r\n\r\nlogin!")
+	conn, err = DialThrough(context.Background(), proxy, "example.com:22", time.Second)
+	if err == nil {
+		conn.Close()
+		t.Fatal("Expected DialThrough to fail on a redirect")
+	}
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || stageErr.Stage != StageConnect {
+		t.Errorf("Expected a connect stage error, got %v", err)
+	}
+}
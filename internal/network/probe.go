@@ -0,0 +1,197 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+// handshakeFunc negotiates a proxy protocol on an open connection and asks
+// the proxy to tunnel to target. It returns the connection to use for the
+// tunnel, which may buffer data the proxy sent after its reply.
+type handshakeFunc func(conn net.Conn, proxy proxylist.Proxy, target string, timings Timings) (net.Conn, error)
+
+// handshakes maps proxy schemes to their tunnelling implementation.
+var handshakes = map[string]handshakeFunc{
+	"http":    httpConnectHandshake,
+	"https":   httpConnectHandshake,
+	"socks4":  exactHandshake(socks4Handshake),
+	"socks4a": exactHandshake(socks4aHandshake),
+	"socks5":  exactHandshake(socks5Handshake),
+	"socks5h": exactHandshake(socks5Handshake),
+}
+
+// exactHandshake adapts a handshake that reads exactly its replies, so the
+// tunnel continues on conn itself.
+func exactHandshake(fn func(conn net.Conn, proxy proxylist.Proxy, target string, timings Timings) error) handshakeFunc {
+	return func(conn net.Conn, proxy proxylist.Proxy, target string, timings Timings) (net.Conn, error) {
+		if err := fn(conn, proxy, target, timings); err != nil {
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// probeOptions controls how a single proxy is checked.
+type probeOptions struct {
+	// Target is the host:port SOCKS proxies are asked to connect to and
+	// HTTP proxies are asked to GET.
+	Target string
+	// TLSTarget is the host:port HTTP proxies are asked to CONNECT to
+	// before a TLS handshake.
+	TLSTarget string
+	Timeout   time.Duration
+	TLSConfig *tls.Config
+}
+
+// probe dials a proxy, measuring the TCP connect latency, and runs a full
+// handshake for its scheme asking it to connect to the check target.
+func probe(ctx context.Context, proxy proxylist.Proxy, opts probeOptions) Result {
+	result := Result{
+		Proxy:     proxy,
+		Timings:   Timings{},
+		CheckedAt: time.Now(),
+	}
+
+	switch proxy.Scheme {
+	case "http", "https":
+		probeHTTP(ctx, &result, opts)
+		return result
+	}
+
+	handshake, ok := handshakes[proxy.Scheme]
+	if !ok {
+		result.fail(fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme))
+		return result
+	}
+
+	err := result.withConn(ctx, opts.Timeout, func(conn net.Conn) error {
+		_, err := handshake(conn, proxy, opts.Target, result.Timings)
+		return err
+	})
+	if err != nil {
+		result.fail(err)
+		return result
+	}
+
+	result.Handshake = true
+	result.OK = true
+	return result
+}
+
+// probeHTTP checks both ways of using an HTTP proxy, each on its own
+// connection: an absolute-URI GET and a CONNECT tunnel followed by a TLS
+// handshake. The mode matching the proxy scheme decides whether the proxy
+// is considered working.
+func probeHTTP(ctx context.Context, result *Result, opts probeOptions) {
+	proxy := result.Proxy
+
+	getErr := result.withConn(ctx, opts.Timeout, func(conn net.Conn) error {
+		return httpGet(conn, proxy, opts.Target, result.Timings)
+	})
+	if getErr == nil {
+		result.Modes = append(result.Modes, ModeGet)
+	}
+
+	connectErr := getErr
+	if result.Connected {
+		connectErr = result.withConn(ctx, opts.Timeout, func(conn net.Conn) error {
+			tunnel, err := httpConnectHandshake(conn, proxy, opts.TLSTarget, result.Timings)
+			if err != nil {
+				return err
+			}
+			return tlsHandshake(ctx, tunnel, opts.TLSTarget, opts.TLSConfig, result.Timings)
+		})
+		if connectErr == nil {
+			result.Modes = append(result.Modes, ModeConnect)
+		}
+	}
+
+	err := getErr
+	if proxy.Scheme == "https" {
+		err = connectErr
+	}
+	if err != nil {
+		result.fail(err)
+	} else {
+		result.OK = true
+	}
+
+	if len(result.Modes) > 0 {
+		result.Handshake = true
+	}
+}
+
+// withConn dials the proxy and runs fn on the connection with the timeout
+// applied as a deadline. Cancelling ctx expires the deadline, so a proxy
+// that stalls mid-handshake does not hold up a cancelled scan. The first
+// successful dial sets the connect latency.
+func (r *Result) withConn(ctx context.Context, timeout time.Duration, fn func(conn net.Conn) error) error {
+	dialer := net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", r.Proxy.Address())
+	if err != nil {
+		return &StageError{Stage: StageDial, Err: err}
+	}
+	defer conn.Close()
+
+	if !r.Connected {
+		r.Connected = true
+		r.Latency = time.Since(start)
+		r.Timings[StageDial] = r.Latency
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return &StageError{Stage: StageDial, Err: err}
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	return fn(conn)
+}
+
+// tlsHandshake runs a TLS client handshake with target over an established
+// tunnel.
+func tlsHandshake(ctx context.Context, conn net.Conn, target string, base *tls.Config, timings Timings) error {
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		return &StageError{Stage: StageTLS, Err: err}
+	}
+
+	cfg := &tls.Config{}
+	if base != nil {
+		cfg = base.Clone()
+	}
+	cfg.ServerName = host
+
+	start := time.Now()
+	if err := tls.Client(conn, cfg).HandshakeContext(ctx); err != nil {
+		return &StageError{Stage: StageTLS, Err: err}
+	}
+	timings[StageTLS] = time.Since(start)
+
+	return nil
+}
+
+// fail records err on the result. A failure past the greeting means the
+// proxy did speak its protocol, so Handshake is set accordingly.
+func (r *Result) fail(err error) {
+	r.Error = err.Error()
+
+	var stageErr *StageError
+	if errors.As(err, &stageErr) {
+		r.FailedStage = stageErr.Stage
+	}
+
+	switch r.FailedStage {
+	case StageAuth, StageConnect, StageRequest, StageTLS:
+		r.Handshake = true
+	}
+}
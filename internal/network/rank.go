@@ -0,0 +1,109 @@
+package network
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/cache"
+	"free-proxy-list-speed-checker/internal/config"
+)
+
+const defaultHistory = 5
+
+// Ranked is a working proxy with its score and the share of recent scans in
+// which it worked.
+type Ranked struct {
+	Result
+	Score        float64
+	SuccessRatio float64
+}
+
+// Rank scores the working proxies of the newest report, fastest first.
+// reports must be ordered oldest to newest; older reports only contribute
+// to the success ratio. Results checked longer than MaxAge ago are dropped.
+// The score is the weighted sum of throughput and latency, each relative to
+// the best proxy in the set, and the success ratio. When all weights are
+// zero, the three components are weighted equally.
+func Rank(reports []*Report, opts config.Ranking) []Ranked {
+	if len(reports) == 0 {
+		return nil
+	}
+
+	if opts.LatencyWeight == 0 && opts.ThroughputWeight == 0 && opts.SuccessWeight == 0 {
+		opts.LatencyWeight, opts.ThroughputWeight, opts.SuccessWeight = 1, 1, 1
+	}
+
+	seen := map[string]int{}
+	worked := map[string]int{}
+	for _, r := range reports {
+		for _, result := range r.Results {
+			key := result.Proxy.String()
+			seen[key]++
+			if result.OK {
+				worked[key]++
+			}
+		}
+	}
+
+	now := time.Now()
+	var ranked []Ranked
+	var bestSpeed float64
+	var bestLatency time.Duration
+	for _, result := range reports[len(reports)-1].Results {
+		if !result.OK {
+			continue
+		}
+		if opts.MaxAge > 0 && now.Sub(result.CheckedAt) > opts.MaxAge {
+			continue
+		}
+
+		key := result.Proxy.String()
+		ranked = append(ranked, Ranked{
+			Result:       result,
+			SuccessRatio: float64(worked[key]) / float64(seen[key]),
+		})
+
+		bestSpeed = max(bestSpeed, result.Speed.BytesPerSec)
+		if result.Latency > 0 && (bestLatency == 0 || result.Latency < bestLatency) {
+			bestLatency = result.Latency
+		}
+	}
+
+	for i := range ranked {
+		r := &ranked[i]
+		if bestSpeed > 0 {
+			r.Score += opts.ThroughputWeight * r.Speed.BytesPerSec / bestSpeed
+		}
+		if r.Latency > 0 {
+			r.Score += opts.LatencyWeight * float64(bestLatency) / float64(r.Latency)
+		}
+		r.Score += opts.SuccessWeight * r.SuccessRatio
+	}
+
+	slices.SortStableFunc(ranked, func(a, b Ranked) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Latency, b.Latency)
+	})
+
+	return ranked
+}
+
+// RankCollection ranks the working proxies of the latest scan of the
+// collection, using the configured number of past scans for the success
+// ratio.
+func RankCollection(c *cache.Cache, collection string, opts config.Ranking) ([]Ranked, error) {
+	history := opts.History
+	if history <= 0 {
+		history = defaultHistory
+	}
+
+	reports, err := RecentReports(c, collection, history)
+	if err != nil {
+		return nil, err
+	}
+
+	return Rank(reports, opts), nil
+}
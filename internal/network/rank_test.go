@@ -0,0 +1,65 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/config"
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+func rankResult(port int, ok bool, latency time.Duration, speed float64, age time.Duration) Result {
+	return Result{
+		Proxy:     proxylist.Proxy{Scheme: "socks5", Host: "10.0.0.1", Port: port},
+		OK:        ok,
+		Latency:   latency,
+		Speed:     Throughput{Bytes: 1, BytesPerSec: speed},
+		CheckedAt: time.Now().Add(-age),
+	}
+}
+
+func TestRank(t *testing.T) {
+	older := &Report{Results: []Result{
+		rankResult(1, false, 0, 0, time.Hour),
+		rankResult(2, true, 10*time.Millisecond, 1000, time.Hour),
+	}}
+	latest := &Report{Results: []Result{
+		rankResult(1, true, 10*time.Millisecond, 1000, 0),
+		rankResult(2, true, 10*time.Millisecond, 1000, 0),
+		rankResult(3, true, 5*time.Millisecond, 500, 0),
+		rankResult(4, false, 0, 0, 0),
+		rankResult(5, true, time.Millisecond, 5000, 48*time.Hour),
+	}}
+
+	tests := []struct {
+		name  string
+		opts  config.Ranking
+		ports []int
+	}{
+		{name: "throughput", opts: config.Ranking{ThroughputWeight: 1, MaxAge: 24 * time.Hour}, ports: []int{1, 2, 3}},
+		{name: "latency", opts: config.Ranking{LatencyWeight: 1, MaxAge: 24 * time.Hour}, ports: []int{3, 1, 2}},
+		{name: "success", opts: config.Ranking{ThroughputWeight: 1, SuccessWeight: 1, MaxAge: 24 * time.Hour}, ports: []int{2, 3, 1}},
+		{name: "no max age", opts: config.Ranking{ThroughputWeight: 1}, ports: []int{5, 1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := Rank([]*Report{older, latest}, tt.opts)
+			if len(ranked) != len(tt.ports) {
+				t.Fatalf("Expected %d ranked proxies, got %d", len(tt.ports), len(ranked))
+			}
+			for i, port := range tt.ports {
+				if ranked[i].Proxy.Port != port {
+					t.Errorf("Position %d: expected port %d, got %d (score %.3f)", i, port, ranked[i].Proxy.Port, ranked[i].Score)
+				}
+			}
+		})
+	}
+
+	ranked := Rank([]*Report{older, latest}, config.Ranking{SuccessWeight: 1})
+	for _, r := range ranked {
+		if r.Proxy.Port == 1 && r.SuccessRatio != 0.5 {
+			t.Errorf("Expected success ratio 0.5 for port 1, got %f", r.SuccessRatio)
+		}
+	}
+}
@@ -1,16 +1,178 @@
 package network
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
 
+	"free-proxy-list-speed-checker/internal/cache"
 	"free-proxy-list-speed-checker/internal/config"
+	"free-proxy-list-speed-checker/internal/proxylist"
 )
 
-func Scan(collection string, cfg *config.Config) error {
-	fmt.Printf("Starting scan for collection: %s\n", collection)
+const (
+	defaultConcurrency = 64
+	defaultTimeout     = 5 * time.Second
+	defaultCheckTarget = "example.com:80"
+	defaultSpeedBytes  = 1 << 20
+	defaultSpeedTime   = 15 * time.Second
+)
+
+// Result holds the outcome of probing a single proxy. Connected is set once
+// the TCP connection is open, Handshake once the proxy has answered in its
+// protocol and OK once it has reached the check target. Modes lists the ways
+// an HTTP proxy could be used. Speed is only measured for working proxies
+// when a download test is configured.
+type Result struct {
+	Proxy       proxylist.Proxy
+	Connected   bool
+	Handshake   bool
+	OK          bool
+	Modes       []Mode
+	Latency     time.Duration
+	Timings     Timings
+	FailedStage Stage
+	Error       string
+	Speed       Throughput
+	SpeedError  string
+	CheckedAt   time.Time
+}
+
+// Report is the outcome of scanning a whole collection.
+type Report struct {
+	Collection string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Malformed  int
+	Results    []Result
+}
+
+// ScanOptions controls a single scan.
+type ScanOptions struct {
+	// ForceRefresh revalidates the proxy list even if the cached copy is
+	// younger than the collection refresh interval.
+	ForceRefresh bool
+}
+
+// Scan downloads the proxy list of the collection through the cache and
+// probes every entry using a bounded pool of workers.
+func Scan(ctx context.Context, collection string, cfg *config.Config, c *cache.Cache, opts ScanOptions) (*Report, error) {
+	col, ok := cfg.ProxyCollectionList[collection]
+	if !ok {
+		return nil, fmt.Errorf("collection %s not found", collection)
+	}
+
+	webOpts := []cache.WebOption{cache.WithMaxAge(col.RefreshInterval)}
+	if opts.ForceRefresh {
+		webOpts = append(webOpts, cache.WithForceRefresh())
+	}
+	if cfg.Options.StaleIfError {
+		webOpts = append(webOpts, cache.WithStaleIfError())
+	}
+
+	content, err := c.GetWeb(col.Url, webOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch collection %s: %w", collection, err)
+	}
+
+	proxies, lineErrs := proxylist.Parse(content)
+	for _, lineErr := range lineErrs {
+		log.Printf("warning: collection %s: %v", collection, lineErr)
+	}
+
+	workers := cfg.Options.ScanConcurrency
+	if workers <= 0 {
+		workers = defaultConcurrency
+	}
+	timeout := cfg.Options.ScanTimeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	target := cfg.Options.CheckTarget
+	if target == "" {
+		target = defaultCheckTarget
+	}
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid check target %s: %w", target, err)
+	}
+	probeOpts := probeOptions{
+		Target:    target,
+		TLSTarget: net.JoinHostPort(host, "443"),
+		Timeout:   timeout,
+	}
+	speed := speedOptions{
+		URL:     cfg.Options.SpeedTestUrl,
+		Bytes:   cfg.Options.SpeedTestBytes,
+		Timeout: cfg.Options.SpeedTestTimeout,
+	}
+	if speed.Bytes <= 0 {
+		speed.Bytes = defaultSpeedBytes
+	}
+	if speed.Timeout <= 0 {
+		speed.Timeout = defaultSpeedTime
+	}
+
+	for i := range proxies {
+		if proxies[i].Scheme == "" {
+			proxies[i].Scheme = col.Protocol
+		}
+	}
+
+	report := &Report{
+		Collection: collection,
+		StartedAt:  time.Now(),
+		Malformed:  len(lineErrs),
+		Results:    make([]Result, len(proxies)),
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(proxies)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				report.Results[i] = check(ctx, proxies[i], probeOpts, speed)
+			}
+		}()
+	}
+
+feed:
+	for i := range proxies {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	report.FinishedAt = time.Now()
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// check probes a proxy and, if it works, measures its download speed.
+func check(ctx context.Context, proxy proxylist.Proxy, opts probeOptions, speed speedOptions) Result {
+	result := probe(ctx, proxy, opts)
+	if !result.OK || speed.URL == "" {
+		return result
+	}
 
-	// TODO: Implement actual scanning logic
+	throughput, err := measureThroughput(ctx, proxy, speed, opts)
+	if err != nil {
+		result.SpeedError = err.Error()
+		return result
+	}
+	result.Speed = throughput
 
-	fmt.Println("Scan completed successfully")
-	return nil
+	return result
 }
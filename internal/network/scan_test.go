@@ -0,0 +1,163 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/cache"
+	"free-proxy-list-speed-checker/internal/config"
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+// serveList serves the given lines as a proxy list.
+func serveList(t *testing.T, lines ...string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Join(lines, "\n"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func scanConfig(url, target string, concurrency int, timeout time.Duration) *config.Config {
+	return &config.Config{
+		ProxyCollectionList: config.ProxyCollectionList{
+			"socks5": {Url: url, Protocol: "socks5", Enabled: true},
+		},
+		Options: config.Options{
+			ScanConcurrency: concurrency,
+			ScanTimeout:     timeout,
+			CheckTarget:     target,
+		},
+	}
+}
+
+func newScanCache(t *testing.T) *cache.Cache {
+	t.Helper()
+	c, err := cache.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestScan(t *testing.T) {
+	target := startTarget(t)
+	good := (&fakeSOCKS5{}).start(t)
+	refused := (&fakeSOCKS5{connectReply: 0x05}).start(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	dead := listenerProxy(t, ln, "socks5")
+	ln.Close()
+
+	url := serveList(t, good.Address(), "not a proxy", refused.Address(), dead.Address())
+	cfg := scanConfig(url, target, 2, time.Second)
+	c := newScanCache(t)
+
+	report, err := Scan(context.Background(), "socks5", cfg, c, ScanOptions{})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	if report.Collection != "socks5" || report.Malformed != 1 || len(report.Results) != 3 {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+	if report.FinishedAt.Before(report.StartedAt) {
+		t.Errorf("Expected the scan to finish after it started: %s, %s", report.StartedAt, report.FinishedAt)
+	}
+
+	want := []struct {
+		proxy proxylist.Proxy
+		ok    bool
+		stage Stage
+	}{
+		{good, true, ""},
+		{refused, false, StageConnect},
+		{dead, false, StageDial},
+	}
+	for i, w := range want {
+		r := report.Results[i]
+		if r.Proxy != w.proxy || r.OK != w.ok || r.FailedStage != w.stage {
+			t.Errorf("Result %d: expected %s ok=%v stage=%q, got %s ok=%v stage=%q (%s)",
+				i, w.proxy, w.ok, w.stage, r.Proxy, r.OK, r.FailedStage, r.Error)
+		}
+	}
+
+	if _, ok := c.Metadata(url); !ok {
+		t.Error("Expected the proxy list to be cached")
+	}
+
+	if _, err := Scan(context.Background(), "missing", cfg, c, ScanOptions{}); err == nil {
+		t.Error("Expected an error for an unknown collection")
+	}
+}
+
+func TestScanCancel(t *testing.T) {
+	// The proxies read the greeting but never answer it.
+	greeted := make(chan struct{}, 10)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if _, err := io.ReadFull(conn, make([]byte, 3)); err == nil {
+					greeted <- struct{}{}
+				}
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+	stalled := listenerProxy(t, ln, "socks5")
+
+	lines := make([]string, 5)
+	for i := range lines {
+		lines[i] = stalled.Address()
+	}
+	cfg := scanConfig(serveList(t, lines...), "example.com:80", 1, time.Minute)
+	c := newScanCache(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-greeted
+		cancel()
+	}()
+
+	start := time.Now()
+	report, err := Scan(ctx, "socks5", cfg, c, ScanOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the scan to stop promptly, took %s", elapsed)
+	}
+
+	if report == nil || len(report.Results) != 5 {
+		t.Fatalf("Expected a partial report with 5 slots, got %+v", report)
+	}
+	if r := report.Results[0]; r.OK || r.FailedStage != StageGreeting {
+		t.Errorf("Expected the stalled probe to fail at the greeting, got %+v", r)
+	}
+	if r := report.Results[len(report.Results)-1]; !r.CheckedAt.IsZero() {
+		t.Errorf("Expected the last proxy not to be probed, got %+v", r)
+	}
+}
@@ -0,0 +1,103 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+const (
+	socks4Version     = 0x04
+	socks4CmdConnect  = 0x01
+	socks4ReplyGrant  = 0x5A
+	socks4ReplyHeader = 0x00
+)
+
+var socks4ReplyMessages = map[byte]string{
+	0x5B: "request rejected or failed",
+	0x5C: "request rejected: identd unreachable",
+	0x5D: "request rejected: identd user mismatch",
+}
+
+// socks4Handshake sends a SOCKS4 CONNECT request for target on conn. Plain
+// SOCKS4 only carries IPv4 addresses, so host names are resolved locally.
+func socks4Handshake(conn net.Conn, proxy proxylist.Proxy, target string, timings Timings) error {
+	return socks4Connect(conn, proxy, target, timings, false)
+}
+
+// socks4aHandshake is socks4Handshake with host names passed to the proxy
+// for remote resolution using the SOCKS4a extension.
+func socks4aHandshake(conn net.Conn, proxy proxylist.Proxy, target string, timings Timings) error {
+	return socks4Connect(conn, proxy, target, timings, true)
+}
+
+// socks4Connect sends the CONNECT request for both SOCKS4 variants. The
+// proxy username is sent as the USERID.
+func socks4Connect(conn net.Conn, proxy proxylist.Proxy, target string, timings Timings, remoteDNS bool) error {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return &StageError{Stage: StageConnect, Err: fmt.Errorf("invalid target %s: %w", target, err)}
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return &StageError{Stage: StageConnect, Err: fmt.Errorf("invalid target port %s", portStr)}
+	}
+
+	var remoteHost string
+	ip := net.ParseIP(host)
+	if ip == nil && !remoteDNS {
+		ips, err := net.DefaultResolver.LookupIP(context.Background(), "ip4", host)
+		if err != nil {
+			return &StageError{Stage: StageConnect, Err: fmt.Errorf("resolve %s: %w", host, err)}
+		}
+		ip = ips[0]
+	}
+	if ip == nil {
+		// SOCKS4a: an address of 0.0.0.x with x != 0 tells the proxy to
+		// resolve the host name that follows the USERID.
+		ip = net.IPv4(0, 0, 0, 1)
+		remoteHost = host
+	}
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return &StageError{Stage: StageConnect, Err: errors.New("SOCKS4 does not support IPv6 targets")}
+	}
+
+	req := []byte{socks4Version, socks4CmdConnect, byte(port >> 8), byte(port)}
+	req = append(req, ip4...)
+	req = append(req, proxy.Username...)
+	req = append(req, 0x00)
+	if remoteHost != "" {
+		req = append(req, remoteHost...)
+		req = append(req, 0x00)
+	}
+
+	start := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return &StageError{Stage: StageGreeting, Err: err}
+	}
+
+	reply := make([]byte, 8)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return &StageError{Stage: StageGreeting, Err: err}
+	}
+	if reply[0] != socks4ReplyHeader {
+		return &StageError{Stage: StageGreeting, Err: fmt.Errorf("not a SOCKS4 server: reply version %#x", reply[0])}
+	}
+	if reply[1] != socks4ReplyGrant {
+		msg, ok := socks4ReplyMessages[reply[1]]
+		if !ok {
+			msg = fmt.Sprintf("reply code %#x", reply[1])
+		}
+		return &StageError{Stage: StageConnect, Err: fmt.Errorf("CONNECT refused: %s", msg)}
+	}
+	timings[StageConnect] = time.Since(start)
+
+	return nil
+}
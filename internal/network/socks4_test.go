@@ -0,0 +1,101 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeSOCKS4 is an in-process SOCKS4/4a server that records the requested
+// destination and answers with reply.
+type fakeSOCKS4 struct {
+	reply byte
+	host  chan string
+}
+
+func (f *fakeSOCKS4) start(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	return ln
+}
+
+func (f *fakeSOCKS4) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	req := make([]byte, 8)
+	if _, err := io.ReadFull(r, req); err != nil {
+		return
+	}
+	if _, err := r.ReadString(0x00); err != nil {
+		return
+	}
+
+	host := net.IP(req[4:8]).String()
+	if req[4] == 0 && req[5] == 0 && req[6] == 0 && req[7] != 0 {
+		name, err := r.ReadString(0x00)
+		if err != nil {
+			return
+		}
+		host = name[:len(name)-1]
+	}
+	f.host <- host
+
+	conn.Write([]byte{socks4ReplyHeader, f.reply, 0, 0, 0, 0, 0, 0})
+}
+
+func TestSOCKS4Probe(t *testing.T) {
+	tests := []struct {
+		name   string
+		scheme string
+		target string
+		reply  byte
+		host   string
+		ok     bool
+		stage  Stage
+	}{
+		{name: "ipv4 target", scheme: "socks4", target: "93.184.216.34:80", reply: socks4ReplyGrant, host: "93.184.216.34", ok: true},
+		{name: "local dns", scheme: "socks4", target: "localhost:80", reply: socks4ReplyGrant, host: "127.0.0.1", ok: true},
+		{name: "remote dns", scheme: "socks4a", target: "example.com:80", reply: socks4ReplyGrant, host: "example.com", ok: true},
+		{name: "rejected", scheme: "socks4a", target: "example.com:80", reply: 0x5B, host: "example.com", stage: StageConnect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeSOCKS4{reply: tt.reply, host: make(chan string, 1)}
+			proxy := listenerProxy(t, server.start(t), tt.scheme)
+
+			r := probe(context.Background(), proxy, probeOptions{Target: tt.target, Timeout: time.Second})
+			if !r.Handshake {
+				t.Fatalf("Expected handshake, got error %q", r.Error)
+			}
+			if r.OK != tt.ok {
+				t.Errorf("OK: expected %v, got %v (%s)", tt.ok, r.OK, r.Error)
+			}
+			if r.FailedStage != tt.stage {
+				t.Errorf("FailedStage: expected %q, got %q", tt.stage, r.FailedStage)
+			}
+			if host := <-server.host; host != tt.host {
+				t.Errorf("Expected proxy to receive host %q, got %q", tt.host, host)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+const (
+	socks5Version = 0x05
+
+	socks5MethodNoAuth       = 0x00
+	socks5MethodUserPass     = 0x02
+	socks5MethodNoAcceptable = 0xFF
+
+	socks5UserPassVersion = 0x01
+
+	socks5CmdConnect = 0x01
+
+	socks5AtypIPv4   = 0x01
+	socks5AtypDomain = 0x03
+	socks5AtypIPv6   = 0x04
+)
+
+var socks5ReplyMessages = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// socks5Handshake negotiates an RFC 1928 session on conn and asks the proxy
+// to CONNECT to target. Username/password authentication (RFC 1929) is
+// offered when the proxy carries credentials. The duration of each stage is
+// recorded in timings.
+func socks5Handshake(conn net.Conn, proxy proxylist.Proxy, target string, timings Timings) error {
+	methods := []byte{socks5MethodNoAuth}
+	if proxy.Username != "" {
+		methods = append(methods, socks5MethodUserPass)
+	}
+
+	start := time.Now()
+	greeting := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return &StageError{Stage: StageGreeting, Err: err}
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return &StageError{Stage: StageGreeting, Err: err}
+	}
+	if reply[0] != socks5Version {
+		return &StageError{Stage: StageGreeting, Err: fmt.Errorf("not a SOCKS5 server: version %#x", reply[0])}
+	}
+	timings[StageGreeting] = time.Since(start)
+
+	switch reply[1] {
+	case socks5MethodNoAuth:
+	case socks5MethodUserPass:
+		if proxy.Username == "" {
+			return &StageError{Stage: StageAuth, Err: errors.New("server requires username/password authentication")}
+		}
+		start = time.Now()
+		if err := socks5Authenticate(conn, proxy.Username, proxy.Password); err != nil {
+			return &StageError{Stage: StageAuth, Err: err}
+		}
+		timings[StageAuth] = time.Since(start)
+	case socks5MethodNoAcceptable:
+		return &StageError{Stage: StageAuth, Err: errors.New("no acceptable authentication methods")}
+	default:
+		return &StageError{Stage: StageAuth, Err: fmt.Errorf("unsupported authentication method %#x", reply[1])}
+	}
+
+	start = time.Now()
+	if err := socks5Connect(conn, target); err != nil {
+		return &StageError{Stage: StageConnect, Err: err}
+	}
+	timings[StageConnect] = time.Since(start)
+
+	return nil
+}
+
+func socks5Authenticate(conn net.Conn, username, password string) error {
+	if len(username) > 255 || len(password) > 255 {
+		return errors.New("username or password too long")
+	}
+
+	req := []byte{socks5UserPassVersion, byte(len(username))}
+	req = append(req, username...)
+	req = append(req, byte(len(password)))
+	req = append(req, password...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[1] != 0x00 {
+		return fmt.Errorf("authentication rejected: status %#x", reply[1])
+	}
+
+	return nil
+}
+
+func socks5Connect(conn net.Conn, target string) error {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return fmt.Errorf("invalid target %s: %w", target, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid target port %s", portStr)
+	}
+
+	req := []byte{socks5Version, socks5CmdConnect, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, socks5AtypIPv4)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, socks5AtypIPv6)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("target host name too long: %s", host)
+		}
+		req = append(req, socks5AtypDomain, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[0] != socks5Version {
+		return fmt.Errorf("unexpected reply version %#x", header[0])
+	}
+	if header[1] != 0x00 {
+		msg, ok := socks5ReplyMessages[header[1]]
+		if !ok {
+			msg = fmt.Sprintf("reply code %#x", header[1])
+		}
+		return fmt.Errorf("CONNECT refused: %s", msg)
+	}
+
+	var addrLen int
+	switch header[3] {
+	case socks5AtypIPv4:
+		addrLen = net.IPv4len
+	case socks5AtypIPv6:
+		addrLen = net.IPv6len
+	case socks5AtypDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return err
+		}
+		addrLen = int(l[0])
+	default:
+		return fmt.Errorf("unexpected bound address type %#x", header[3])
+	}
+
+	// Discard the bound address and port.
+	if _, err := io.ReadFull(conn, make([]byte, addrLen+2)); err != nil {
+		return err
+	}
+
+	return nil
+}
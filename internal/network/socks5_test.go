@@ -0,0 +1,253 @@
+package network
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+// fakeSOCKS5 is an in-process SOCKS5 server. It relays CONNECT requests to
+// the requested target unless connectReply is set to a failure code.
+type fakeSOCKS5 struct {
+	username     string
+	password     string
+	connectReply byte
+}
+
+func (f *fakeSOCKS5) start(t *testing.T) proxylist.Proxy {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	return listenerProxy(t, ln, "socks5")
+}
+
+func (f *fakeSOCKS5) serve(conn net.Conn) {
+	defer conn.Close()
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+
+	if f.username != "" {
+		offered := false
+		for _, m := range methods {
+			if m == socks5MethodUserPass {
+				offered = true
+			}
+		}
+		if !offered {
+			conn.Write([]byte{socks5Version, socks5MethodNoAcceptable})
+			return
+		}
+		conn.Write([]byte{socks5Version, socks5MethodUserPass})
+
+		buf := make([]byte, 2)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		user := make([]byte, buf[1])
+		io.ReadFull(conn, user)
+		io.ReadFull(conn, buf[:1])
+		pass := make([]byte, buf[0])
+		io.ReadFull(conn, pass)
+		if string(user) != f.username || string(pass) != f.password {
+			conn.Write([]byte{socks5UserPassVersion, 0x01})
+			return
+		}
+		conn.Write([]byte{socks5UserPassVersion, 0x00})
+	} else {
+		conn.Write([]byte{socks5Version, socks5MethodNoAuth})
+	}
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case socks5AtypIPv4:
+		ip := make([]byte, net.IPv4len)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case socks5AtypIPv6:
+		ip := make([]byte, net.IPv6len)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case socks5AtypDomain:
+		l := make([]byte, 1)
+		io.ReadFull(conn, l)
+		name := make([]byte, l[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	}
+	port := make([]byte, 2)
+	io.ReadFull(conn, port)
+	target := net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1])))
+
+	reply := []byte{socks5Version, f.connectReply, 0x00, socks5AtypIPv4, 0, 0, 0, 0, 0, 0}
+	if f.connectReply != 0x00 {
+		conn.Write(reply)
+		return
+	}
+
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		reply[1] = 0x05
+		conn.Write(reply)
+		return
+	}
+	defer upstream.Close()
+	conn.Write(reply)
+
+	go io.Copy(upstream, conn)
+	io.Copy(conn, upstream)
+}
+
+func listenerProxy(t *testing.T, ln net.Listener, scheme string) proxylist.Proxy {
+	t.Helper()
+	addr := ln.Addr().(*net.TCPAddr)
+	return proxylist.Proxy{Scheme: scheme, Host: addr.IP.String(), Port: addr.Port}
+}
+
+// startTarget starts a TCP server that accepts and immediately closes
+// connections, standing in for the check target.
+func startTarget(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestSOCKS5Probe(t *testing.T) {
+	target := startTarget(t)
+
+	tests := []struct {
+		name      string
+		server    fakeSOCKS5
+		username  string
+		password  string
+		handshake bool
+		ok        bool
+		stage     Stage
+	}{
+		{name: "no auth", handshake: true, ok: true},
+		{name: "user/pass", server: fakeSOCKS5{username: "u", password: "p"}, username: "u", password: "p", handshake: true, ok: true},
+		{name: "missing credentials", server: fakeSOCKS5{username: "u", password: "p"}, handshake: true, stage: StageAuth},
+		{name: "wrong credentials", server: fakeSOCKS5{username: "u", password: "p"}, username: "u", password: "x", handshake: true, stage: StageAuth},
+		{name: "connect refused", server: fakeSOCKS5{connectReply: 0x05}, handshake: true, stage: StageConnect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := tt.server.start(t)
+			proxy.Username = tt.username
+			proxy.Password = tt.password
+
+			r := probe(context.Background(), proxy, probeOptions{Target: target, Timeout: time.Second})
+			if !r.Connected {
+				t.Fatalf("Expected TCP connection, got error %q", r.Error)
+			}
+			if r.Handshake != tt.handshake {
+				t.Errorf("Handshake: expected %v, got %v (%s)", tt.handshake, r.Handshake, r.Error)
+			}
+			if r.OK != tt.ok {
+				t.Errorf("OK: expected %v, got %v (%s)", tt.ok, r.OK, r.Error)
+			}
+			if r.FailedStage != tt.stage {
+				t.Errorf("FailedStage: expected %q, got %q", tt.stage, r.FailedStage)
+			}
+			if tt.ok {
+				for _, stage := range []Stage{StageDial, StageGreeting, StageConnect} {
+					if _, ok := r.Timings[stage]; !ok {
+						t.Errorf("Expected timing for stage %s", stage)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSOCKS5ProbeNotSOCKS(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+			conn.Close()
+		}
+	}()
+
+	r := probe(context.Background(), listenerProxy(t, ln, "socks5"), probeOptions{Target: "example.com:80", Timeout: time.Second})
+	if !r.Connected {
+		t.Fatalf("Expected TCP connection, got error %q", r.Error)
+	}
+	if r.Handshake {
+		t.Error("Expected handshake to fail against a non-SOCKS server")
+	}
+	if r.FailedStage != StageGreeting {
+		t.Errorf("FailedStage: expected %q, got %q", StageGreeting, r.FailedStage)
+	}
+}
+
+func TestSOCKS5ProbeDialFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	proxy := listenerProxy(t, ln, "socks5")
+	ln.Close()
+
+	r := probe(context.Background(), proxy, probeOptions{Target: "example.com:80", Timeout: time.Second})
+	if r.Connected {
+		t.Fatal("Expected dial to fail")
+	}
+	if r.FailedStage != StageDial {
+		t.Errorf("FailedStage: expected %q, got %q", StageDial, r.FailedStage)
+	}
+}
@@ -0,0 +1,36 @@
+package network
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stage names a step of a proxy check.
+type Stage string
+
+const (
+	StageDial     Stage = "dial"
+	StageGreeting Stage = "greeting"
+	StageAuth     Stage = "auth"
+	StageConnect  Stage = "connect"
+	StageRequest  Stage = "request"
+	StageTLS      Stage = "tls"
+)
+
+// Timings holds the duration of every completed stage of a check.
+type Timings map[Stage]time.Duration
+
+// StageError is returned when a check fails, recording the stage at which
+// it did.
+type StageError struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,76 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/cache"
+)
+
+// ErrNoReport is returned when a collection has not been scanned yet.
+var ErrNoReport = errors.New("no scan results")
+
+const reportKeyLayout = "20060102T150405.000000000Z"
+
+// reportPrefix returns the cache key prefix shared by all reports of a
+// collection. Keys sort chronologically because the timestamp is UTC with a
+// fixed width.
+func reportPrefix(collection string) string {
+	return "scan/" + collection + "/"
+}
+
+func reportKey(collection string, startedAt time.Time) string {
+	return reportPrefix(collection) + startedAt.UTC().Format(reportKeyLayout)
+}
+
+// SaveReport stores the report in the cache keyed by collection and scan
+// start time.
+func SaveReport(c *cache.Cache, r *Report) error {
+	key := reportKey(r.Collection, r.StartedAt)
+	if err := cache.SetTyped(c, key, cache.TypeScanResult, *r); err != nil {
+		return fmt.Errorf("failed to save scan results for %s: %w", r.Collection, err)
+	}
+	return nil
+}
+
+// RecentReports loads up to n of the most recent reports of the collection,
+// ordered oldest to newest.
+func RecentReports(c *cache.Cache, collection string, n int) ([]*Report, error) {
+	keys := c.Keys(reportPrefix(collection), cache.TypeScanResult)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w for collection %s", ErrNoReport, collection)
+	}
+	if n > 0 && len(keys) > n {
+		keys = keys[len(keys)-n:]
+	}
+
+	reports := make([]*Report, 0, len(keys))
+	for _, key := range keys {
+		report, err := cache.GetTyped[Report](c, key, cache.TypeScanResult)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, &report)
+	}
+
+	return reports, nil
+}
+
+// LatestReport loads the most recent report of the collection together
+// with its cache metadata.
+func LatestReport(c *cache.Cache, collection string) (*Report, cache.Metadata, error) {
+	keys := c.Keys(reportPrefix(collection), cache.TypeScanResult)
+	if len(keys) == 0 {
+		return nil, cache.Metadata{}, fmt.Errorf("%w for collection %s", ErrNoReport, collection)
+	}
+
+	key := keys[len(keys)-1]
+	report, err := cache.GetTyped[Report](c, key, cache.TypeScanResult)
+	if err != nil {
+		return nil, cache.Metadata{}, err
+	}
+
+	metadata, _ := c.Metadata(key)
+	return &report, metadata, nil
+}
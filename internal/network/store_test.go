@@ -0,0 +1,57 @@
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/cache"
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+func TestLatestReport(t *testing.T) {
+	c, err := cache.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("cache.Close: %v", err)
+		}
+	})
+
+	if _, _, err := LatestReport(c, "socks5"); !errors.Is(err, ErrNoReport) {
+		t.Fatalf("Expected ErrNoReport before any scan, got %v", err)
+	}
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, latency := range []time.Duration{time.Second, 2 * time.Second} {
+		report := &Report{
+			Collection: "socks5",
+			StartedAt:  start.Add(time.Duration(i) * time.Hour),
+			Results: []Result{{
+				Proxy:   proxylist.Proxy{Scheme: "socks5", Host: "1.2.3.4", Port: 1080},
+				OK:      true,
+				Latency: latency,
+				Timings: Timings{StageDial: latency},
+			}},
+		}
+		if err := SaveReport(c, report); err != nil {
+			t.Fatalf("SaveReport: %v", err)
+		}
+	}
+
+	report, metadata, err := LatestReport(c, "socks5")
+	if err != nil {
+		t.Fatalf("LatestReport: %v", err)
+	}
+	if !report.StartedAt.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected latest report from %s, got %s", start.Add(time.Hour), report.StartedAt)
+	}
+	if got := report.Results[0].Timings[StageDial]; got != 2*time.Second {
+		t.Errorf("Expected dial timing 2s, got %s", got)
+	}
+	if metadata.Type != cache.TypeScanResult {
+		t.Errorf("Expected metadata type %s, got %s", cache.TypeScanResult, metadata.Type)
+	}
+}
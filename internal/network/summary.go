@@ -0,0 +1,100 @@
+package network
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+)
+
+// stageOrder lists check stages in the order they run.
+var stageOrder = []Stage{StageDial, StageGreeting, StageAuth, StageConnect, StageRequest, StageTLS}
+
+// Percentiles holds the p50/p90/p99 of a sample, using the nearest-rank
+// method. Count is the sample size; the percentiles are zero when it is 0.
+type Percentiles[T cmp.Ordered] struct {
+	Count int
+	P50   T
+	P90   T
+	P99   T
+}
+
+// StageFailures is the number of proxies that failed at a stage.
+type StageFailures struct {
+	Stage Stage
+	Count int
+}
+
+// Summary aggregates the results of a scan report.
+type Summary struct {
+	Parsed     int
+	Malformed  int
+	Reachable  int
+	Handshake  int
+	Working    int
+	Failures   []StageFailures
+	Latency    Percentiles[time.Duration]
+	Throughput Percentiles[float64]
+}
+
+// Summarize computes statistics over a report. Latency percentiles cover
+// reachable proxies and throughput percentiles cover proxies with a
+// completed download test.
+func Summarize(r *Report) Summary {
+	s := Summary{
+		Parsed:    len(r.Results),
+		Malformed: r.Malformed,
+	}
+
+	failures := map[Stage]int{}
+	var latencies []time.Duration
+	var speeds []float64
+	for _, result := range r.Results {
+		if result.Connected {
+			s.Reachable++
+			latencies = append(latencies, result.Latency)
+		}
+		if result.Handshake {
+			s.Handshake++
+		}
+		if result.OK {
+			s.Working++
+		} else if result.FailedStage != "" {
+			failures[result.FailedStage]++
+		}
+		if result.Speed.Bytes > 0 {
+			speeds = append(speeds, result.Speed.BytesPerSec)
+		}
+	}
+
+	for _, stage := range stageOrder {
+		if n := failures[stage]; n > 0 {
+			s.Failures = append(s.Failures, StageFailures{Stage: stage, Count: n})
+		}
+	}
+
+	s.Latency = percentiles(latencies)
+	s.Throughput = percentiles(speeds)
+
+	return s
+}
+
+func percentiles[T cmp.Ordered](values []T) Percentiles[T] {
+	p := Percentiles[T]{Count: len(values)}
+	if len(values) == 0 {
+		return p
+	}
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	rank := func(q float64) T {
+		i := int(math.Ceil(q*float64(len(sorted)))) - 1
+		return sorted[max(i, 0)]
+	}
+	p.P50 = rank(0.50)
+	p.P90 = rank(0.90)
+	p.P99 = rank(0.99)
+
+	return p
+}
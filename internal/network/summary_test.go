@@ -0,0 +1,59 @@
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentiles(t *testing.T) {
+	values := make([]int, 100)
+	for i := range values {
+		values[i] = 100 - i
+	}
+
+	p := percentiles(values)
+	if p.Count != 100 || p.P50 != 50 || p.P90 != 90 || p.P99 != 99 {
+		t.Errorf("Expected count=100 p50=50 p90=90 p99=99, got %+v", p)
+	}
+
+	if p := percentiles([]int{7}); p.P50 != 7 || p.P99 != 7 {
+		t.Errorf("Expected single value percentiles of 7, got %+v", p)
+	}
+	if p := percentiles[int](nil); p.Count != 0 || p.P50 != 0 {
+		t.Errorf("Expected zero percentiles for empty input, got %+v", p)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	report := &Report{
+		Malformed: 2,
+		Results: []Result{
+			{FailedStage: StageDial},
+			{Connected: true, Latency: 30 * time.Millisecond, FailedStage: StageGreeting},
+			{Connected: true, Handshake: true, Latency: 20 * time.Millisecond, FailedStage: StageConnect},
+			{Connected: true, Handshake: true, OK: true, Latency: 10 * time.Millisecond, Speed: Throughput{Bytes: 1, BytesPerSec: 1000}},
+		},
+	}
+
+	s := Summarize(report)
+	if s.Parsed != 4 || s.Malformed != 2 || s.Reachable != 3 || s.Handshake != 2 || s.Working != 1 {
+		t.Errorf("Unexpected counts: %+v", s)
+	}
+
+	want := []StageFailures{{StageDial, 1}, {StageGreeting, 1}, {StageConnect, 1}}
+	if len(s.Failures) != len(want) {
+		t.Fatalf("Expected failures %v, got %v", want, s.Failures)
+	}
+	for i := range want {
+		if s.Failures[i] != want[i] {
+			t.Errorf("Failure %d: expected %v, got %v", i, want[i], s.Failures[i])
+		}
+	}
+
+	if s.Latency.P50 != 20*time.Millisecond || s.Latency.P99 != 30*time.Millisecond {
+		t.Errorf("Unexpected latency percentiles: %+v", s.Latency)
+	}
+	if s.Throughput.Count != 1 || s.Throughput.P50 != 1000 {
+		t.Errorf("Unexpected throughput percentiles: %+v", s.Throughput)
+	}
+}
@@ -0,0 +1,127 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+// Throughput is the result of downloading a payload through a proxy.
+type Throughput struct {
+	TTFB        time.Duration
+	Total       time.Duration
+	Bytes       int64
+	BytesPerSec float64
+}
+
+// speedOptions controls the download test run against working proxies.
+type speedOptions struct {
+	// URL is downloaded through the proxy. An empty URL disables the test.
+	URL string
+	// Bytes caps how much of the response body is read.
+	Bytes   int64
+	Timeout time.Duration
+}
+
+// measureThroughput downloads up to opts.Bytes from opts.URL through proxy
+// and reports time-to-first-byte, total time and the transfer rate.
+func measureThroughput(ctx context.Context, proxy proxylist.Proxy, opts speedOptions, probeOpts probeOptions) (Throughput, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	transport := &http.Transport{
+		TLSClientConfig:   probeOpts.TLSConfig,
+		DisableKeepAlives: true,
+	}
+	switch proxy.Scheme {
+	case "http", "https":
+		proxyURL := &url.URL{Scheme: "http", Host: proxy.Address()}
+		if proxy.Username != "" {
+			proxyURL.User = url.UserPassword(proxy.Username, proxy.Password)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	default:
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return DialThrough(ctx, proxy, addr, probeOpts.Timeout)
+		}
+	}
+	defer transport.CloseIdleConnections()
+
+	var firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, opts.URL, nil)
+	if err != nil {
+		return Throughput{}, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	start := time.Now()
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return Throughput{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Throughput{}, fmt.Errorf("download responded %s", resp.Status)
+	}
+
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, opts.Bytes))
+	total := time.Since(start)
+	if err != nil && !(errors.Is(err, context.DeadlineExceeded) && n > 0) {
+		return Throughput{}, err
+	}
+	if n == 0 {
+		return Throughput{}, errors.New("download returned no data")
+	}
+
+	return Throughput{
+		TTFB:        firstByte.Sub(start),
+		Total:       total,
+		Bytes:       n,
+		BytesPerSec: float64(n) / total.Seconds(),
+	}, nil
+}
+
+// DialThrough opens a connection to target tunnelled through proxy. The
+// handshake must complete within timeout; the returned connection has no
+// deadline.
+func DialThrough(ctx context.Context, proxy proxylist.Proxy, target string, timeout time.Duration) (net.Conn, error) {
+	handshake, ok := handshakes[proxy.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme)
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", proxy.Address())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tunnel, err := handshake(conn, proxy, target, Timings{})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tunnel, nil
+}
@@ -0,0 +1,108 @@
+package network
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"free-proxy-list-speed-checker/internal/config"
+	"free-proxy-list-speed-checker/internal/proxylist"
+)
+
+// payloadServer serves n deterministic bytes for /bytes?n=<n>.
+func payloadServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(r.URL.Query().Get("n"))
+		if err != nil {
+			http.Error(w, "bad n", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(n))
+		chunk := make([]byte, 4096)
+		for i := range chunk {
+			chunk[i] = byte(i)
+		}
+		for n > 0 {
+			k := min(n, len(chunk))
+			if _, err := w.Write(chunk[:k]); err != nil {
+				return
+			}
+			n -= k
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMeasureThroughput(t *testing.T) {
+	srv := payloadServer(t)
+	socks := (&fakeSOCKS5{}).start(t)
+	httpProxy := (&fakeHTTPProxy{}).start(t, "http")
+
+	tests := []struct {
+		name   string
+		proxy  proxylist.Proxy
+		served int
+		limit  int64
+		want   int64
+	}{
+		{name: "socks5 capped", proxy: socks, served: 256 << 10, limit: 64 << 10, want: 64 << 10},
+		{name: "socks5 short body", proxy: socks, served: 10000, limit: 64 << 10, want: 10000},
+		{name: "http capped", proxy: httpProxy, served: 256 << 10, limit: 100000, want: 100000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			speed := speedOptions{
+				URL:     srv.URL + "/bytes?n=" + strconv.Itoa(tt.served),
+				Bytes:   tt.limit,
+				Timeout: 5 * time.Second,
+			}
+
+			got, err := measureThroughput(context.Background(), tt.proxy, speed, probeOptions{Timeout: time.Second})
+			if err != nil {
+				t.Fatalf("measureThroughput: %v", err)
+			}
+			if got.Bytes != tt.want {
+				t.Errorf("Expected %d bytes, got %d", tt.want, got.Bytes)
+			}
+			if got.TTFB <= 0 || got.TTFB > got.Total {
+				t.Errorf("Expected 0 < TTFB <= Total, got TTFB=%s Total=%s", got.TTFB, got.Total)
+			}
+			if got.BytesPerSec <= 0 {
+				t.Errorf("Expected positive throughput, got %f", got.BytesPerSec)
+			}
+		})
+	}
+}
+
+func TestCheckFeedsRank(t *testing.T) {
+	srv := payloadServer(t)
+	target := startTarget(t)
+	working := (&fakeSOCKS5{}).start(t)
+	refusing := (&fakeSOCKS5{connectReply: 0x05}).start(t)
+
+	opts := probeOptions{Target: target, Timeout: time.Second}
+	speed := speedOptions{URL: srv.URL + "/bytes?n=32768", Bytes: 32768, Timeout: 5 * time.Second}
+
+	results := []Result{
+		check(context.Background(), refusing, opts, speed),
+		check(context.Background(), working, opts, speed),
+	}
+
+	if results[0].Speed.Bytes != 0 {
+		t.Errorf("Expected no download through a refusing proxy, got %d bytes", results[0].Speed.Bytes)
+	}
+	if results[1].Speed.Bytes != 32768 {
+		t.Fatalf("Expected 32768 bytes through working proxy, got %d (%s)", results[1].Speed.Bytes, results[1].SpeedError)
+	}
+
+	ranked := Rank([]*Report{{Results: results}}, config.Ranking{})
+	if len(ranked) != 1 || ranked[0].Proxy != working {
+		t.Fatalf("Expected only the working proxy to be ranked, got %+v", ranked)
+	}
+}
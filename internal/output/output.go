@@ -0,0 +1,281 @@
+// Package output writes command results in machine-readable formats.
+//
+// Results are slices of flat record structs. Field names come from the
+// json struct tags, so every format shares one schema. Nested structs are
+// allowed; CSV flattens them into dotted column names. Slices of scalars
+// are written as lists, and as ";"-separated values in CSV.
+package output
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Format is an output format selected with the -output flag.
+type Format string
+
+const (
+	Text  Format = "text"
+	JSON  Format = "json"
+	JSONL Format = "jsonl"
+	CSV   Format = "csv"
+	YAML  Format = "yaml"
+)
+
+// Formats lists the supported formats.
+var Formats = []Format{Text, JSON, JSONL, CSV, YAML}
+
+// ParseFormat converts the -output flag value into a Format. An empty
+// value selects Text.
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return Text, nil
+	}
+	for _, f := range Formats {
+		if Format(s) == f {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format %q", s)
+}
+
+// Write encodes records, which must be a slice of structs, in format. JSON
+// and YAML write a single list; JSONL and CSV write one record per line.
+// Text is not handled here: commands print it themselves.
+func Write(w io.Writer, format Format, records any) error {
+	v := reflect.ValueOf(records)
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("output: records must be a slice of structs, got %T", records)
+	}
+
+	switch format {
+	case JSON:
+		if v.IsNil() {
+			records = reflect.MakeSlice(v.Type(), 0, 0).Interface()
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+
+	case JSONL:
+		enc := json.NewEncoder(w)
+		for i := range v.Len() {
+			if err := enc.Encode(v.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case CSV:
+		return writeCSV(w, v)
+
+	case YAML:
+		return writeYAML(w, v)
+	}
+
+	return fmt.Errorf("output: format %q cannot encode records", format)
+}
+
+// field is a column of a record: its name and the index path to reach it.
+type field struct {
+	name  string
+	index []int
+}
+
+// fields returns the exported fields of t named by their json tags.
+// Fields tagged "-" are skipped.
+func fields(t reflect.Type) []field {
+	var result []field
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		result = append(result, field{name: name, index: f.Index})
+	}
+	return result
+}
+
+// isScalar reports whether values of t are written as a single value.
+func isScalar(t reflect.Type) bool {
+	if t.Implements(textMarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		return false
+	}
+	return true
+}
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+// columns flattens the fields of t into CSV columns.
+func columns(t reflect.Type, prefix string, index []int) []field {
+	var result []field
+	for _, f := range fields(t) {
+		name := prefix + f.name
+		path := append(index[:len(index):len(index)], f.index...)
+		ft := t.FieldByIndex(f.index).Type
+		if ft.Kind() == reflect.Struct && !isScalar(ft) {
+			result = append(result, columns(ft, name+".", path)...)
+			continue
+		}
+		result = append(result, field{name: name, index: path})
+	}
+	return result
+}
+
+func writeCSV(w io.Writer, records reflect.Value) error {
+	cols := columns(records.Type().Elem(), "", nil)
+
+	cw := csv.NewWriter(w)
+	header := make([]string, len(cols))
+	for i, col := range cols {
+		header[i] = col.name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	row := make([]string, len(cols))
+	for i := range records.Len() {
+		record := records.Index(i)
+		for j, col := range cols {
+			value, err := csvValue(record.FieldByIndex(col.index))
+			if err != nil {
+				return fmt.Errorf("output: column %s: %w", col.name, err)
+			}
+			row[j] = value
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvValue(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Slice && isScalar(v.Type().Elem()) {
+		items := make([]string, v.Len())
+		for i := range v.Len() {
+			item, err := scalar(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			items[i] = item
+		}
+		return strings.Join(items, ";"), nil
+	}
+	return scalar(v)
+}
+
+// scalar formats a single value as plain text.
+func scalar(v reflect.Value) (string, error) {
+	if v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	}
+
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// writeYAML writes records as a block sequence of mappings. Strings are
+// always double-quoted using JSON escaping, which is valid YAML.
+func writeYAML(w io.Writer, records reflect.Value) error {
+	if records.Len() == 0 {
+		_, err := fmt.Fprintln(w, "[]")
+		return err
+	}
+
+	var b strings.Builder
+	for i := range records.Len() {
+		if err := yamlMapping(&b, records.Index(i), "- ", "  "); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// yamlMapping writes the fields of a struct. The first line is prefixed by
+// first and the following lines by indent.
+func yamlMapping(b *strings.Builder, v reflect.Value, first, indent string) error {
+	prefix := first
+	for _, f := range fields(v.Type()) {
+		fv := v.FieldByIndex(f.index)
+		b.WriteString(prefix)
+		prefix = indent
+		b.WriteString(f.name)
+		b.WriteString(":")
+
+		switch {
+		case fv.Kind() == reflect.Struct && !isScalar(fv.Type()):
+			b.WriteString("\n")
+			if err := yamlMapping(b, fv, indent+"  ", indent+"  "); err != nil {
+				return err
+			}
+			continue
+
+		case fv.Kind() == reflect.Slice && isScalar(fv.Type().Elem()):
+			items := make([]string, fv.Len())
+			for i := range fv.Len() {
+				item, err := yamlScalar(fv.Index(i))
+				if err != nil {
+					return fmt.Errorf("output: field %s: %w", f.name, err)
+				}
+				items[i] = item
+			}
+			b.WriteString(" [" + strings.Join(items, ", ") + "]\n")
+			continue
+		}
+
+		value, err := yamlScalar(fv)
+		if err != nil {
+			return fmt.Errorf("output: field %s: %w", f.name, err)
+		}
+		b.WriteString(" " + value + "\n")
+	}
+	return nil
+}
+
+func yamlScalar(v reflect.Value) (string, error) {
+	text, err := scalar(v)
+	if err != nil {
+		return "", err
+	}
+	if v.Kind() == reflect.String || v.Type().Implements(textMarshalerType) {
+		quoted, err := json.Marshal(text)
+		return string(quoted), err
+	}
+	return text, nil
+}
@@ -0,0 +1,140 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type timing struct {
+	P50 float64 `json:"p50"`
+	P99 float64 `json:"p99"`
+}
+
+type record struct {
+	Name    string    `json:"name"`
+	Port    int       `json:"port"`
+	OK      bool      `json:"ok"`
+	Modes   []string  `json:"modes"`
+	Latency timing    `json:"latency"`
+	At      time.Time `json:"at"`
+	Hidden  string    `json:"-"`
+}
+
+var at = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+var records = []record{
+	{Name: "a", Port: 1080, OK: true, Modes: []string{"get", "connect"}, Latency: timing{1.5, 3}, At: at, Hidden: "x"},
+	{Name: "b,\"c\"", Port: 8080, Modes: nil, At: at},
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"", "text", "json", "jsonl", "csv", "yaml"} {
+		if _, err := ParseFormat(s); err != nil {
+			t.Errorf("Expected %q to parse, got %v", s, err)
+		}
+	}
+	if f, _ := ParseFormat(""); f != Text {
+		t.Errorf("Expected empty format to select text, got %q", f)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, JSON, records); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var decoded []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if len(decoded) != 2 || decoded[0]["name"] != "a" || decoded[0]["latency"].(map[string]any)["p99"] != 3.0 {
+		t.Errorf("Unexpected JSON output: %s", buf.String())
+	}
+	if _, ok := decoded[0]["Hidden"]; ok {
+		t.Error("Expected fields tagged - to be skipped")
+	}
+
+	buf.Reset()
+	if err := Write(&buf, JSON, []record(nil)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "[]" {
+		t.Errorf("Expected an empty list for no records, got %s", got)
+	}
+}
+
+func TestWriteJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, JSONL, records); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d:\n%s", len(lines), buf.String())
+	}
+	for _, line := range lines {
+		var r record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Errorf("Line is not a JSON record: %v: %s", err, line)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, CSV, records); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	want := "name,port,ok,modes,latency.p50,latency.p99,at\n" +
+		"a,1080,true,get;connect,1.5,3,2024-05-01T12:00:00Z\n" +
+		"\"b,\"\"c\"\"\",8080,false,,0,0,2024-05-01T12:00:00Z\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected CSV output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, YAML, records[:1]); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	want := `- name: "a"
+  port: 1080
+  ok: true
+  modes: ["get", "connect"]
+  latency:
+    p50: 1.5
+    p99: 3
+  at: "2024-05-01T12:00:00Z"
+`
+	if buf.String() != want {
+		t.Errorf("Unexpected YAML output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := Write(&buf, YAML, []record{}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if buf.String() != "[]\n" {
+		t.Errorf("Expected an empty list for no records, got %q", buf.String())
+	}
+}
+
+func TestWriteRejectsNonRecords(t *testing.T) {
+	if err := Write(new(bytes.Buffer), JSON, map[string]int{}); err == nil {
+		t.Error("Expected an error for a non-slice value")
+	}
+	if err := Write(new(bytes.Buffer), Text, records); err == nil {
+		t.Error("Expected an error for the text format")
+	}
+}
@@ -0,0 +1,155 @@
+package proxylist
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Schemes lists the proxy schemes accepted in a "scheme://" prefix.
+var Schemes = []string{"http", "https", "socks4", "socks4a", "socks5", "socks5h"}
+
+// Proxy is a single entry parsed from a proxy list.
+type Proxy struct {
+	Scheme   string
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// Address returns the host:port pair suitable for net.Dial.
+func (p Proxy) Address() string {
+	return net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+}
+
+// String returns the proxy as a URL. Credentials are included when present.
+func (p Proxy) String() string {
+	var b strings.Builder
+	if p.Scheme != "" {
+		b.WriteString(p.Scheme)
+		b.WriteString("://")
+	}
+	if p.Username != "" {
+		b.WriteString(p.Username)
+		if p.Password != "" {
+			b.WriteString(":")
+			b.WriteString(p.Password)
+		}
+		b.WriteString("@")
+	}
+	b.WriteString(p.Address())
+	return b.String()
+}
+
+// LineError describes a malformed line in a proxy list.
+type LineError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %q: %v", e.Line, e.Text, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Parse reads a proxy list, one entry per line. Blank lines and lines
+// starting with '#' are skipped, as are trailing " #" comments. Malformed
+// lines are reported with their line number and do not stop parsing.
+func Parse(data []byte) ([]Proxy, []*LineError) {
+	var proxies []Proxy
+	var errs []*LineError
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := stripComment(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		p, err := ParseLine(line)
+		if err != nil {
+			errs = append(errs, &LineError{Line: lineNo, Text: line, Err: err})
+			continue
+		}
+		proxies = append(proxies, p)
+	}
+
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, &LineError{Line: lineNo + 1, Err: err})
+	}
+
+	return proxies, errs
+}
+
+// ParseLine parses a single entry in one of the forms host:port,
+// scheme://host:port or user:pass@host:port. IPv6 hosts must be bracketed.
+func ParseLine(line string) (Proxy, error) {
+	var p Proxy
+	rest := strings.TrimSpace(line)
+
+	if scheme, after, ok := strings.Cut(rest, "://"); ok {
+		scheme = strings.ToLower(scheme)
+		if !slices.Contains(Schemes, scheme) {
+			return Proxy{}, fmt.Errorf("unsupported scheme %q", scheme)
+		}
+		p.Scheme = scheme
+		rest = after
+	}
+
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		user, pass, _ := strings.Cut(rest[:i], ":")
+		if user == "" {
+			return Proxy{}, errors.New("empty username")
+		}
+		p.Username = user
+		p.Password = pass
+		rest = rest[i+1:]
+	}
+
+	rest = strings.TrimSuffix(rest, "/")
+	host, portStr, err := net.SplitHostPort(rest)
+	if err != nil {
+		return Proxy{}, err
+	}
+	if host == "" {
+		return Proxy{}, errors.New("missing host")
+	}
+	if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		return Proxy{}, fmt.Errorf("invalid IPv6 address %q", host)
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return Proxy{}, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	p.Host = host
+	p.Port = port
+	return p, nil
+}
+
+func stripComment(line string) string {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "#") {
+		return ""
+	}
+	if i := strings.Index(line, " #"); i >= 0 {
+		line = line[:i]
+	}
+	if i := strings.Index(line, "\t#"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,81 @@
+package proxylist
+
+import (
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want Proxy
+	}{
+		{"1.2.3.4:1080", Proxy{Host: "1.2.3.4", Port: 1080}},
+		{"socks5://1.2.3.4:1080", Proxy{Scheme: "socks5", Host: "1.2.3.4", Port: 1080}},
+		{"HTTP://proxy.example.com:8080/", Proxy{Scheme: "http", Host: "proxy.example.com", Port: 8080}},
+		{"user:pa:ss@1.2.3.4:1080", Proxy{Host: "1.2.3.4", Port: 1080, Username: "user", Password: "pa:ss"}},
+		{"socks5://user:pass@[2001:db8::1]:1080", Proxy{Scheme: "socks5", Host: "2001:db8::1", Port: 1080, Username: "user", Password: "pass"}},
+		{"[::1]:3128", Proxy{Host: "::1", Port: 3128}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLine(tt.line)
+		if err != nil {
+			t.Errorf("ParseLine(%q): unexpected error: %v", tt.line, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	lines := []string{
+		"1.2.3.4",
+		"1.2.3.4:0",
+		"1.2.3.4:70000",
+		"1.2.3.4:http",
+		":1080",
+		"2001:db8::1:1080",
+		"[zzzz::1]:1080",
+		"ftp://1.2.3.4:21",
+		"@1.2.3.4:1080",
+	}
+
+	for _, line := range lines {
+		if p, err := ParseLine(line); err == nil {
+			t.Errorf("ParseLine(%q): expected error, got %+v", line, p)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	data := []byte(`# free proxy list
+1.2.3.4:1080
+
+socks5://5.6.7.8:1080 # trailing comment
+not-a-proxy
+[::1]:9050
+`)
+
+	proxies, errs := Parse(data)
+	if len(proxies) != 3 {
+		t.Fatalf("Expected 3 proxies, got %d: %+v", len(proxies), proxies)
+	}
+	if proxies[1].String() != "socks5://5.6.7.8:1080" {
+		t.Errorf("Expected socks5://5.6.7.8:1080, got %s", proxies[1].String())
+	}
+	if proxies[2].Address() != "[::1]:9050" {
+		t.Errorf("Expected [::1]:9050, got %s", proxies[2].Address())
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 malformed line, got %d: %v", len(errs), errs)
+	}
+	if errs[0].Line != 5 {
+		t.Errorf("Expected malformed line 5, got %d", errs[0].Line)
+	}
+	if errs[0].Text != "not-a-proxy" {
+		t.Errorf("Expected malformed text %q, got %q", "not-a-proxy", errs[0].Text)
+	}
+}